@@ -0,0 +1,366 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	bumpNone  = "none"
+	bumpPatch = "patch"
+	bumpMinor = "minor"
+	bumpMajor = "major"
+)
+
+// defaultBumps are the parts of the version bumped by the prefixes that do
+// not have a bump of their own. Breaking changes always bump the major part.
+var defaultBumps = map[string]string{
+	"feat": bumpMinor,
+	"fix":  bumpPatch,
+}
+
+var bumpOrder = []string{bumpNone, bumpPatch, bumpMinor, bumpMajor}
+
+var errNoBump = errors.New("no commits that call for a new version")
+
+// semver is a semantic version without build metadata.
+type semver struct {
+	major int
+	minor int
+	patch int
+	pre   string
+}
+
+const semverPattern = `(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z.-]+))?`
+
+func (v semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if v.pre != "" {
+		s += "-" + v.pre
+	}
+	return s
+}
+
+func comparePrerelease(a string, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil && an != bn:
+			if an < bn {
+				return -1
+			}
+			return 1
+		// Numeric identifiers have a lower precedence
+		case aErr == nil && bErr != nil:
+			return -1
+		case aErr != nil && bErr == nil:
+			return 1
+		case as[i] != bs[i]:
+			if as[i] < bs[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(as) - len(bs)
+}
+
+// compare returns a negative number if v comes before other, a positive one
+// if it comes after it and zero if they are the same.
+func (v semver) compare(other semver) int {
+	for _, d := range []int{v.major - other.major, v.minor - other.minor, v.patch - other.patch} {
+		if d != 0 {
+			return d
+		}
+	}
+	return comparePrerelease(v.pre, other.pre)
+}
+
+// next returns the version after this one for the bump. A pre-release that
+// already has the bump applied is released as is, or, when the pre-release
+// identifier is given, continued, e.g. 1.3.0-rc.0 becomes 1.3.0-rc.1.
+func (v semver) next(bump string, pre string) semver {
+	next := semver{major: v.major, minor: v.minor, patch: v.patch}
+	covered := v.pre != "" && (bump == bumpPatch ||
+		bump == bumpMinor && v.patch == 0 ||
+		bump == bumpMajor && v.minor == 0 && v.patch == 0)
+	if !covered {
+		switch bump {
+		case bumpMajor:
+			next = semver{major: v.major + 1}
+		case bumpMinor:
+			next = semver{major: v.major, minor: v.minor + 1}
+		case bumpPatch:
+			next.patch++
+		}
+	}
+	if pre == "" {
+		return next
+	}
+
+	next.pre = pre + ".0"
+	if covered && strings.HasPrefix(v.pre, pre+".") {
+		if n, err := strconv.Atoi(strings.TrimPrefix(v.pre, pre+".")); err == nil {
+			next.pre = fmt.Sprintf("%s.%d", pre, n+1)
+		}
+	}
+	return next
+}
+
+// tagPattern returns a pattern matching the tags of the format, with the
+// version in the submatches.
+func tagPattern(format string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(format)
+	return regexp.MustCompile("^" + strings.Replace(quoted, regexp.QuoteMeta("{version}"), semverPattern, 1) + "$")
+}
+
+func tagFormat(c *config) string {
+	if c.TagFormat == "" || !strings.Contains(c.TagFormat, "{version}") {
+		return defaultTagFormat
+	}
+	return c.TagFormat
+}
+
+func tagName(format string, v semver) string {
+	return strings.Replace(format, "{version}", v.String(), 1)
+}
+
+// latestVersion returns the tag with the highest version that is reachable
+// from the revision, if there is one, optionally leaving out pre-releases.
+func latestVersion(format string, rev string, stable bool) (string, semver, bool, error) {
+	tags, err := mergedTags(rev)
+	if err != nil {
+		return "", semver{}, false, err
+	}
+
+	pattern := tagPattern(format)
+	var latest string
+	var version semver
+	for _, tag := range tags {
+		s := pattern.FindStringSubmatch(tag)
+		if s == nil {
+			continue
+		}
+		v := semver{pre: s[4]}
+		v.major, _ = strconv.Atoi(s[1])
+		v.minor, _ = strconv.Atoi(s[2])
+		v.patch, _ = strconv.Atoi(s[3])
+		if stable && v.pre != "" {
+			continue
+		}
+		if latest == "" || v.compare(version) > 0 {
+			latest, version = tag, v
+		}
+	}
+	return latest, version, latest != "", nil
+}
+
+// normalizeBumps lowercases the bumps of the prefixes and makes sure each is
+// one of the parts of the version or none.
+func normalizeBumps(c *config) error {
+	// Copied as the prefixes may still be the defaults
+	c.Prefixes = append([]prefix(nil), c.Prefixes...)
+	for i, p := range c.Prefixes {
+		if p.Bump == "" {
+			continue
+		}
+		bump := strings.ToLower(strings.TrimSpace(p.Bump))
+		if !contains(bumpOrder, bump) {
+			return fmt.Errorf("unknown bump for prefix %s: %q, expected major, minor, patch or none", p.T, p.Bump)
+		}
+		c.Prefixes[i].Bump = bump
+	}
+	return nil
+}
+
+// bumpOf returns the part of the version a commit bumps.
+func bumpOf(c *config, msg commitMessage) string {
+	if msg.IsBreaking() {
+		return bumpMajor
+	}
+	for _, p := range c.Prefixes {
+		if p.T == msg.Prefix && p.Bump != "" {
+			return p.Bump
+		}
+	}
+	if bump, ok := defaultBumps[msg.Prefix]; ok {
+		return bump
+	}
+	return bumpNone
+}
+
+func greaterBump(a string, b string) string {
+	for _, bump := range bumpOrder {
+		if bump == b {
+			return a
+		}
+		if bump == a {
+			return b
+		}
+	}
+	return a
+}
+
+// releaseBump returns the greatest bump among the commits of the release.
+func releaseBump(c *config, r release) string {
+	bump := bumpNone
+	for _, e := range r.Entries {
+		bump = greaterBump(bump, bumpOf(c, e.Message))
+	}
+	return bump
+}
+
+// plannedRelease is a release that is about to be made, along with the files
+// to update for it, relative to dir.
+type plannedRelease struct {
+	dir          string
+	versionFiles []versionFile
+	current      string
+	next         string
+	release      release
+}
+
+// planRelease works out the next version of the tags of the format from the
+// commits since the latest one, keeping only some of the commits if keep is
+// set.
+func planRelease(c *config, format string, increment string, pre string, keep func(changelogEntry) (bool, error)) (plannedRelease, error) {
+	var plan plannedRelease
+	previous, current, found, err := latestVersion(format, "HEAD", false)
+	if err != nil {
+		return plan, err
+	}
+	// The commits since the last stable release decide the bump, so that
+	// pre-releases lead up to the same version
+	if current.pre != "" {
+		if previous, _, _, err = latestVersion(format, "HEAD", true); err != nil {
+			return plan, err
+		}
+	}
+	r, err := collectRelease(c, previous, "HEAD")
+	if err != nil {
+		return plan, err
+	}
+	if keep != nil {
+		if r, err = filterRelease(c, r, keep); err != nil {
+			return plan, err
+		}
+	}
+
+	bump := releaseBump(c, r)
+	// A package without commits of its own is not released, whatever the
+	// increment
+	if increment != "" && (keep == nil || len(r.Entries) > 0) {
+		bump = increment
+	}
+
+	var next semver
+	switch {
+	case !found && bump == bumpNone:
+		return plan, errNoBump
+	// The first version is the one the commits warrant from 0.0.0
+	case !found:
+		next = semver{}.next(bump, pre)
+	case bump == bumpNone:
+		return plan, fmt.Errorf("%w since %s", errNoBump, previous)
+	default:
+		next = current.next(bump, pre)
+	}
+	r.Version = tagName(format, next)
+
+	plan.release, plan.next = r, next.String()
+	if found {
+		plan.current = current.String()
+	}
+	return plan, nil
+}
+
+func runBump(c *config, args []string) error {
+	flags := flag.NewFlagSet("bump", flag.ExitOnError)
+	increment := flags.String("increment", "", "bump this part of the version regardless of the commits: major, minor or patch")
+	pre := flags.String("pre", "", "make the version a pre-release with this identifier, e.g. rc")
+	commit := flags.Bool("commit", false, "update the versionFiles and changelogFile and commit them as chore(release)")
+	tag := flags.Bool("tag", false, "create an annotated tag with the changelog of the release as its message")
+	pkg := flags.String("package", "", "only bump the package with this path when packages are configured")
+	_ = flags.Parse(args)
+
+	if err := findGitDir(); err != nil {
+		return err
+	}
+	if *increment != "" && !contains(bumpOrder[1:], *increment) {
+		return fmt.Errorf("unknown increment: %s", *increment)
+	}
+	if err := normalizeBumps(c); err != nil {
+		return err
+	}
+
+	var plans []plannedRelease
+	if len(c.Packages) == 0 {
+		plan, err := planRelease(c, tagFormat(c), *increment, *pre, nil)
+		if err != nil {
+			return err
+		}
+		plan.versionFiles = c.VersionFiles
+		plans = append(plans, plan)
+	} else {
+		packages, err := selectPackages(c, *pkg)
+		if err != nil {
+			return err
+		}
+		attribution := newPackageAttribution(c)
+		for _, p := range packages {
+			plan, err := planRelease(c, p.tagFormat(c), *increment, *pre, attribution.filter(p))
+			// Only the packages with changes are released
+			if errors.Is(err, errNoBump) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			plan.dir, plan.versionFiles = p.Path, p.VersionFiles
+			plans = append(plans, plan)
+		}
+		if len(plans) == 0 {
+			return fmt.Errorf("%w in any package", errNoBump)
+		}
+	}
+
+	if !*commit && !*tag {
+		for _, plan := range plans {
+			fmt.Println(plan.release.Version)
+		}
+		return nil
+	}
+
+	if *commit {
+		if err := commitRelease(c, plans); err != nil {
+			return err
+		}
+	}
+	if !*tag {
+		return nil
+	}
+
+	renderer := newChangelogRenderer(c)
+	for _, plan := range plans {
+		if err := createTag(plan.release.Version, renderer.markdown(plan.release)); err != nil {
+			return err
+		}
+		fmt.Printf("Created tag %s\n", plan.release.Version)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestSemverNext(t *testing.T) {
+	tests := []struct {
+		version semver
+		bump    string
+		pre     string
+		want    string
+	}{
+		{semver{1, 2, 3, ""}, bumpNone, "", "1.2.3"},
+		{semver{1, 2, 3, ""}, bumpPatch, "", "1.2.4"},
+		{semver{1, 2, 3, ""}, bumpMinor, "", "1.3.0"},
+		{semver{1, 2, 3, ""}, bumpMajor, "", "2.0.0"},
+		{semver{1, 2, 3, ""}, bumpMinor, "rc", "1.3.0-rc.0"},
+		{semver{1, 3, 0, "rc.0"}, bumpMinor, "", "1.3.0"},
+		{semver{1, 3, 0, "rc.0"}, bumpPatch, "", "1.3.0"},
+		{semver{1, 3, 0, "rc.0"}, bumpMinor, "rc", "1.3.0-rc.1"},
+		{semver{1, 3, 0, "rc.9"}, bumpPatch, "rc", "1.3.0-rc.10"},
+		{semver{1, 3, 0, "beta.2"}, bumpMinor, "rc", "1.3.0-rc.0"},
+		{semver{1, 3, 0, "rc.0"}, bumpMajor, "rc", "2.0.0-rc.0"},
+		{semver{1, 3, 1, "rc.0"}, bumpMinor, "", "1.4.0"},
+		{semver{2, 0, 0, "rc.1"}, bumpMinor, "rc", "2.0.0-rc.2"},
+		{semver{0, 0, 0, ""}, bumpMinor, "", "0.1.0"},
+	}
+	for _, tt := range tests {
+		if got := tt.version.next(tt.bump, tt.pre).String(); got != tt.want {
+			t.Errorf("%s.next(%q, %q) = %s, want %s", tt.version, tt.bump, tt.pre, got, tt.want)
+		}
+	}
+}
+
+func TestComparePrerelease(t *testing.T) {
+	// In increasing order of precedence, following the example of the
+	// Semantic Versioning specification
+	ordered := []string{"alpha", "alpha.1", "alpha.beta", "beta", "beta.2", "beta.11", "rc.1", ""}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			got := comparePrerelease(a, b)
+			switch {
+			case i < j && got >= 0:
+				t.Errorf("comparePrerelease(%q, %q) = %d, want < 0", a, b, got)
+			case i > j && got <= 0:
+				t.Errorf("comparePrerelease(%q, %q) = %d, want > 0", a, b, got)
+			case i == j && got != 0:
+				t.Errorf("comparePrerelease(%q, %q) = %d, want 0", a, b, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	unreleasedVersion   = "Unreleased"
+	styleConventional   = "conventional"
+	styleKeepAChangelog = "keep-a-changelog"
+)
+
+// defaultSections are the section titles of the default prefixes, used when a
+// prefix has no section of its own.
+var defaultSections = map[string]string{
+	"feat":     "Features",
+	"fix":      "Bug Fixes",
+	"docs":     "Documentation",
+	"test":     "Tests",
+	"build":    "Build System",
+	"ci":       "Continuous Integration",
+	"perf":     "Performance Improvements",
+	"refactor": "Code Refactoring",
+	"revert":   "Reverts",
+	"style":    "Styles",
+	"chore":    "Miscellaneous Chores",
+}
+
+// keepAChangelogSections are the section titles used with the Keep a
+// Changelog style, which has a fixed set of headings in a set order.
+var (
+	keepAChangelogSections = map[string]string{
+		"feat":     "Added",
+		"fix":      "Fixed",
+		"docs":     "Changed",
+		"perf":     "Changed",
+		"refactor": "Changed",
+		"revert":   "Changed",
+	}
+	keepAChangelogOrder = []string{"Added", "Changed", "Deprecated", "Removed", "Fixed", "Security"}
+)
+
+var (
+	releaseHeadingPattern = regexp.MustCompile(`^## `)
+	linkReferencePattern  = regexp.MustCompile(`^\[[^\]]+\]: `)
+	issuePattern          = regexp.MustCompile(`(^|[\s(])#(\d+)\b`)
+	remotePattern         = regexp.MustCompile(`^(?:https?://|git@|ssh://git@)([^/:]+)[/:](.+?)(?:\.git)?/?$`)
+)
+
+// changelogEntry is a commit that follows the conventions, with the fields
+// exported for use in templates.
+type changelogEntry struct {
+	SHA      string
+	ShortSHA string
+	Author   string
+	Email    string
+	Date     time.Time
+	Message  commitMessage
+	// The issues referenced in the header or by the footers
+	Issues []string
+}
+
+// BreakingChange returns the description of the breaking change, which is
+// the header unless a footer describes it.
+func (e changelogEntry) BreakingChange() string {
+	for _, f := range e.Message.Footers {
+		if f.Token == "BREAKING CHANGE" || f.Token == "BREAKING-CHANGE" {
+			return f.Value
+		}
+	}
+	return e.Message.Subject
+}
+
+type changelogSection struct {
+	Prefix  string
+	Title   string
+	Entries []changelogEntry
+}
+
+// release is everything that happened between two revisions, grouped the
+// way it appears in a changelog.
+type release struct {
+	Version  string
+	Date     time.Time
+	From     string
+	To       string
+	Entries  []changelogEntry
+	Breaking []changelogEntry
+	Sections []changelogSection
+}
+
+func changelogStyle(c *config) string {
+	if c.ChangelogStyle == styleKeepAChangelog {
+		return styleKeepAChangelog
+	}
+	return styleConventional
+}
+
+// sectionTitle returns the heading of the section of a prefix. With the Keep
+// a Changelog style, prefixes without a heading of their own are left out
+// unless there is a fitting one.
+func sectionTitle(c *config, p prefix) (string, bool) {
+	if p.Section != "" {
+		return p.Section, true
+	}
+	if changelogStyle(c) == styleKeepAChangelog {
+		title, ok := keepAChangelogSections[p.T]
+		return title, ok
+	}
+	if title, ok := defaultSections[p.T]; ok {
+		return title, true
+	}
+	return p.T, true
+}
+
+func sectionIndex(title string) int {
+	for i, t := range keepAChangelogOrder {
+		if t == title {
+			return i
+		}
+	}
+	return len(keepAChangelogOrder)
+}
+
+// issueReferences returns the issue numbers mentioned in the subject and in
+// the footers, e.g. "Closes #12".
+func issueReferences(msg commitMessage) []string {
+	var issues []string
+	texts := []string{msg.Subject}
+	for _, f := range msg.Footers {
+		texts = append(texts, f.Value)
+	}
+	for _, text := range texts {
+		for _, s := range issuePattern.FindAllStringSubmatch(text, -1) {
+			if !contains(issues, s[2]) {
+				issues = append(issues, s[2])
+			}
+		}
+	}
+	return issues
+}
+
+// collectRelease parses the commits in the range, leaving out those that do
+// not follow the conventions or were generated by git.
+func collectRelease(c *config, from string, to string) (release, error) {
+	r := release{From: from, To: to, Date: time.Now()}
+	revs := []string{"--no-merges", to}
+	if from != "" {
+		revs = []string{"--no-merges", from + ".." + to}
+	}
+	commits, err := commitsInRange(revs...)
+	if err != nil {
+		return r, err
+	}
+	if len(commits) > 0 {
+		r.Date = commits[0].date
+	}
+
+	// git log lists the newest commits first, which is also the order in
+	// the changelog
+	for _, commit := range commits {
+		msg := parseCommitMessage(commit.message)
+		if msg.Prefix == "" || skipLinting(msg.Header()) {
+			continue
+		}
+		r.Entries = append(r.Entries, changelogEntry{
+			SHA:      commit.sha,
+			ShortSHA: commit.sha[:7],
+			Author:   commit.author,
+			Email:    commit.email,
+			Date:     commit.date,
+			Message:  msg,
+			Issues:   issueReferences(msg),
+		})
+	}
+	return groupRelease(c, r), nil
+}
+
+// filterRelease returns the release with only the commits that are kept.
+func filterRelease(c *config, r release, keep func(changelogEntry) (bool, error)) (release, error) {
+	entries := r.Entries
+	r.Entries = nil
+	for _, e := range entries {
+		ok, err := keep(e)
+		if err != nil {
+			return r, err
+		}
+		if ok {
+			r.Entries = append(r.Entries, e)
+		}
+	}
+	return groupRelease(c, r), nil
+}
+
+// groupRelease sorts the commits of the release into its sections.
+func groupRelease(c *config, r release) release {
+	r.Breaking, r.Sections = nil, nil
+	for _, e := range r.Entries {
+		if e.Message.IsBreaking() {
+			r.Breaking = append(r.Breaking, e)
+		}
+	}
+	// Prefixes with the same heading share a section
+	sections := make(map[string]int)
+	for _, p := range c.Prefixes {
+		title, ok := sectionTitle(c, p)
+		if p.ExcludeFromChangelog || !ok {
+			continue
+		}
+		var entries []changelogEntry
+		for _, e := range r.Entries {
+			if e.Message.Prefix == p.T {
+				entries = append(entries, e)
+			}
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		if i, ok := sections[title]; ok {
+			r.Sections[i].Entries = append(r.Sections[i].Entries, entries...)
+			continue
+		}
+		sections[title] = len(r.Sections)
+		r.Sections = append(r.Sections, changelogSection{Prefix: p.T, Title: title, Entries: entries})
+	}
+
+	if changelogStyle(c) == styleKeepAChangelog {
+		sort.SliceStable(r.Sections, func(i, j int) bool {
+			return sectionIndex(r.Sections[i].Title) < sectionIndex(r.Sections[j].Title)
+		})
+	}
+	return r
+}
+
+// linkTemplates returns the URL templates for commits and issues, falling
+// back to the ones of GitHub or GitLab when the origin is hosted there.
+func linkTemplates(c *config) (string, string) {
+	commitURL, issueURL := c.CommitURL, c.IssueURL
+	if commitURL != "" || issueURL != "" {
+		return commitURL, issueURL
+	}
+
+	s := remotePattern.FindStringSubmatch(gitConfig("remote.origin.url", ""))
+	if s == nil {
+		return "", ""
+	}
+	base := "https://" + s[1] + "/" + s[2]
+	switch {
+	case s[1] == "github.com":
+		return base + "/commit/{sha}", base + "/issues/{id}"
+	case strings.Contains(s[1], "gitlab"):
+		return base + "/-/commit/{sha}", base + "/-/issues/{id}"
+	default:
+		return "", ""
+	}
+}
+
+type changelogRenderer struct {
+	style     string
+	commitURL string
+	issueURL  string
+}
+
+func newChangelogRenderer(c *config) changelogRenderer {
+	commitURL, issueURL := linkTemplates(c)
+	return changelogRenderer{style: changelogStyle(c), commitURL: commitURL, issueURL: issueURL}
+}
+
+func (cr changelogRenderer) commitLink(e changelogEntry) string {
+	if cr.commitURL == "" {
+		return e.ShortSHA
+	}
+	return fmt.Sprintf("[%s](%s)", e.ShortSHA, strings.ReplaceAll(cr.commitURL, "{sha}", e.SHA))
+}
+
+func (cr changelogRenderer) issueLink(id string) string {
+	if cr.issueURL == "" {
+		return "#" + id
+	}
+	return fmt.Sprintf("[#%s](%s)", id, strings.ReplaceAll(cr.issueURL, "{id}", id))
+}
+
+// linkIssues turns the issue references in the text into links.
+func (cr changelogRenderer) linkIssues(text string) string {
+	if cr.issueURL == "" {
+		return text
+	}
+	return issuePattern.ReplaceAllStringFunc(text, func(match string) string {
+		s := issuePattern.FindStringSubmatch(match)
+		return s[1] + cr.issueLink(s[2])
+	})
+}
+
+func (cr changelogRenderer) entry(e changelogEntry, text string) string {
+	var b strings.Builder
+	b.WriteString("- ")
+	if e.Message.Scope != "" {
+		fmt.Fprintf(&b, "**%s:** ", e.Message.Scope)
+	}
+	fmt.Fprintf(&b, "%s (%s)", cr.linkIssues(text), cr.commitLink(e))
+
+	// Issues only referenced by the footers are listed after the commit
+	inSubject := issueReferences(commitMessage{Subject: e.Message.Subject})
+	var closes []string
+	for _, id := range e.Issues {
+		if !contains(inSubject, id) {
+			closes = append(closes, cr.issueLink(id))
+		}
+	}
+	if len(closes) > 0 {
+		b.WriteString(", closes " + strings.Join(closes, ", "))
+	}
+	return b.String()
+}
+
+// markdown renders the release as a section of a changelog.
+func (cr changelogRenderer) markdown(r release) string {
+	var b strings.Builder
+	if cr.style == styleKeepAChangelog {
+		fmt.Fprintf(&b, "## [%s] - %s\n", r.Version, r.Date.Format("2006-01-02"))
+	} else {
+		fmt.Fprintf(&b, "## %s (%s)\n", r.Version, r.Date.Format("2006-01-02"))
+	}
+
+	if len(r.Breaking) > 0 {
+		b.WriteString("\n### ⚠ BREAKING CHANGES\n\n")
+		for _, e := range r.Breaking {
+			b.WriteString(cr.entry(e, e.BreakingChange()) + "\n")
+		}
+	}
+	for _, s := range r.Sections {
+		fmt.Fprintf(&b, "\n### %s\n\n", s.Title)
+		for _, e := range s.Entries {
+			b.WriteString(cr.entry(e, e.Message.Subject) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// releaseRange returns the range to use when none is given, which is from the
+// latest tag before the end of the range as found by the given function.
+func releaseRange(from string, to string, latest func(rev string) (string, error)) (string, string, error) {
+	if to == "" {
+		to = "HEAD"
+	}
+	if from != "" {
+		return from, to, nil
+	}
+	rev := to
+	// Otherwise the tag at the end of the range would be found
+	if isTag(to) {
+		rev = to + "^"
+	}
+	from, err := latest(rev)
+	return from, to, err
+}
+
+// anyTag finds the latest tag for releaseRange, whatever its name.
+func anyTag(rev string) (string, error) {
+	return latestTag(rev), nil
+}
+
+// releaseVersion returns the version unless it is empty, in which case it is
+// the tag at the end of the range, if there is one.
+func releaseVersion(version string, to string) string {
+	switch {
+	case version != "":
+		return version
+	case isTag(to):
+		return to
+	default:
+		return unreleasedVersion
+	}
+}
+
+func runChangelog(c *config, args []string) error {
+	flags := flag.NewFlagSet("changelog", flag.ExitOnError)
+	from := flags.String("from", "", "start of the range, defaults to the latest tag")
+	to := flags.String("to", "HEAD", "end of the range")
+	version := flags.String("version", "", "heading of the section, defaults to the tag at the end of the range or \""+unreleasedVersion+"\"")
+	output := flags.String("output", "", "write the changelog to a file instead of standard output")
+	update := flags.String("update", "", "add the release to an existing changelog, keeping everything that is already in it")
+	pkg := flags.String("package", "", "only include the package with this path when packages are configured")
+	_ = flags.Parse(args)
+
+	if err := findGitDir(); err != nil {
+		return err
+	}
+	if len(c.Packages) > 0 {
+		return packageChangelogs(c, *pkg, *from, *to, *version, *output, *update)
+	}
+
+	start, end, err := releaseRange(*from, *to, anyTag)
+	if err != nil {
+		return err
+	}
+	r, err := collectRelease(c, start, end)
+	if err != nil {
+		return err
+	}
+	r.Version = releaseVersion(*version, end)
+
+	text := newChangelogRenderer(c).markdown(r)
+	if *update != "" {
+		return prependRelease(*update, r.Version, text)
+	}
+	if *output == "" {
+		fmt.Print(text)
+		return nil
+	}
+	return os.WriteFile(*output, []byte(text), 0644)
+}
+
+// packageChangelogs renders a section for each package with changes, each
+// over the range since its own latest tag. With update, the section is added
+// to the changelog of that name in the directory of the package.
+func packageChangelogs(c *config, name string, from string, to string, version string, output string, update string) error {
+	packages, err := selectPackages(c, name)
+	if err != nil {
+		return err
+	}
+	root, err := repoRoot()
+	if err != nil {
+		return err
+	}
+
+	renderer := newChangelogRenderer(c)
+	attribution := newPackageAttribution(c)
+	var texts []string
+	for _, p := range packages {
+		format := p.tagFormat(c)
+		start, end, err := releaseRange(from, to, versionTag(format))
+		if err != nil {
+			return err
+		}
+		r, err := collectRelease(c, start, end)
+		if err != nil {
+			return err
+		}
+		if r, err = filterRelease(c, r, attribution.filter(p)); err != nil {
+			return err
+		}
+		// A package that was asked for is listed even without changes
+		if len(r.Entries) == 0 && name == "" {
+			continue
+		}
+		r.Version = version
+		if r.Version == "" {
+			r.Version = unreleasedVersion
+			if tagPattern(format).MatchString(end) {
+				r.Version = end
+			}
+		}
+
+		text := renderer.markdown(r)
+		if update != "" {
+			if err := prependRelease(filepath.Join(root, p.Path, update), r.Version, text); err != nil {
+				return err
+			}
+			continue
+		}
+		texts = append(texts, fmt.Sprintf("# %s\n\n%s", p.name(), text))
+	}
+	if update != "" {
+		return nil
+	}
+
+	text := strings.Join(texts, "\n")
+	if output == "" {
+		fmt.Print(text)
+		return nil
+	}
+	return os.WriteFile(output, []byte(text), 0644)
+}
+
+// prependRelease adds the section of a release to a changelog above the
+// previous releases, leaving the introduction, an Unreleased section, any
+// edits and the link references at the end as they are.
+func prependRelease(path string, version string, section string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	text, err := prependSection(path, string(data), version, section)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(text), 0644)
+}
+
+// prependSection returns the text of a changelog with the section of a
+// release added, where an empty text is that of a new changelog.
+func prependSection(path string, text string, version string, section string) (string, error) {
+	if text == "" {
+		return "# Changelog\n\n" + section, nil
+	}
+
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	insert := len(lines)
+	for i, line := range lines {
+		if !releaseHeadingPattern.MatchString(line) {
+			continue
+		}
+		// The version is the first word of the heading, possibly in brackets
+		name := strings.Fields(strings.NewReplacer("[", " ", "]", " ").Replace(line[len("## "):]))
+		switch {
+		case len(name) > 0 && strings.EqualFold(name[0], unreleasedVersion):
+			continue
+		case len(name) > 0 && name[0] == version:
+			return "", fmt.Errorf("%s already has a section for %s", path, version)
+		case insert == len(lines):
+			insert = i
+		}
+	}
+	// Without previous releases, above the link references at the end
+	if insert == len(lines) {
+		for insert > 0 && (linkReferencePattern.MatchString(lines[insert-1]) || strings.TrimSpace(lines[insert-1]) == "") {
+			insert--
+		}
+	}
+
+	before := strings.TrimRight(strings.Join(lines[:insert], "\n"), "\n")
+	after := strings.TrimLeft(strings.Join(lines[insert:], "\n"), "\n")
+	updated := section
+	if before != "" {
+		updated = before + "\n\n" + section
+	}
+	if after != "" {
+		updated += "\n" + after
+	}
+	return updated + "\n", nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// commitizenFiles are the places Commitizen looks for its configuration, in
+// the same order. The TOML files hold it under [tool.commitizen] and the
+// JSON and YAML ones under a "commitizen" key.
+var commitizenFiles = []string{"pyproject.toml", ".cz.toml", ".cz.json", "cz.json", ".cz.yaml", "cz.yaml", "cz.toml"}
+
+// czCustomizableFiles are the default places of a cz-customizable
+// configuration, which package.json can point elsewhere.
+var czCustomizableFiles = []string{".cz-config.js", ".cz-config.cjs"}
+
+// conventionalBumpMap is the bump map Commitizen uses for conventional
+// commits unless it is customized.
+var conventionalBumpMap = map[string]string{
+	"^feat":     "MINOR",
+	"^fix":      "PATCH",
+	"^refactor": "PATCH",
+	"^perf":     "PATCH",
+}
+
+type czChoice struct {
+	Value string `toml:"value" yaml:"value"`
+	Name  string `toml:"name" yaml:"name"`
+}
+
+type czQuestion struct {
+	Name    string     `toml:"name" yaml:"name"`
+	Choices []czChoice `toml:"choices" yaml:"choices"`
+}
+
+type commitizenConfig struct {
+	Name         string   `toml:"name" yaml:"name"`
+	TagFormat    string   `toml:"tag_format" yaml:"tag_format"`
+	VersionFiles []string `toml:"version_files" yaml:"version_files"`
+	Customize    struct {
+		MessageTemplate string            `toml:"message_template" yaml:"message_template"`
+		SchemaPattern   string            `toml:"schema_pattern" yaml:"schema_pattern"`
+		BumpMap         map[string]string `toml:"bump_map" yaml:"bump_map"`
+		ChangeTypeMap   map[string]string `toml:"change_type_map" yaml:"change_type_map"`
+		Questions       []czQuestion      `toml:"questions" yaml:"questions"`
+	} `toml:"customize" yaml:"customize"`
+
+	// Set when the configuration is that of cz-customizable instead, which
+	// has a different layout
+	customizable *czCustomizableConfig
+}
+
+type czCustomizableConfig struct {
+	Types            []czChoice    `yaml:"types"`
+	Scopes           []interface{} `yaml:"scopes"`
+	SubjectLimit     int           `yaml:"subjectLimit"`
+	UpperCaseSubject bool          `yaml:"upperCaseSubject"`
+}
+
+// findCommitizenConfig looks for a Commitizen or cz-customizable
+// configuration in the current directory and then at the root of the
+// repository. A cz-customizable one is only evaluated when allowed, as that
+// runs code from the repository.
+func findCommitizenConfig(evaluateJS bool) (commitizenConfig, bool) {
+	dirs := []string{"."}
+	if root, err := repoRoot(); err == nil {
+		dirs = append(dirs, root)
+	}
+
+	for _, dir := range dirs {
+		for _, name := range commitizenFiles {
+			if c, ok := readCommitizenConfig(filepath.Join(dir, name)); ok {
+				return c, true
+			}
+		}
+		if !evaluateJS {
+			continue
+		}
+		for _, path := range czCustomizablePaths(dir) {
+			if c, ok := readCzCustomizableConfig(path); ok {
+				return commitizenConfig{customizable: &c}, true
+			}
+		}
+	}
+	return commitizenConfig{}, false
+}
+
+func readCommitizenConfig(path string) (commitizenConfig, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return commitizenConfig{}, false
+	}
+
+	if filepath.Ext(path) == ".toml" {
+		var file struct {
+			Tool struct {
+				Commitizen *commitizenConfig `toml:"commitizen"`
+			} `toml:"tool"`
+		}
+		if _, err := toml.Decode(string(data), &file); err != nil || file.Tool.Commitizen == nil {
+			return commitizenConfig{}, false
+		}
+		return *file.Tool.Commitizen, true
+	}
+
+	var file struct {
+		Commitizen *commitizenConfig `yaml:"commitizen"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil || file.Commitizen == nil {
+		return commitizenConfig{}, false
+	}
+	return *file.Commitizen, true
+}
+
+// czCustomizablePaths returns where the cz-customizable configuration may
+// be, starting with the path given in package.json.
+func czCustomizablePaths(dir string) []string {
+	var paths []string
+	if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		var pkg struct {
+			Config struct {
+				CzCustomizable struct {
+					Config string `yaml:"config"`
+				} `yaml:"cz-customizable"`
+			} `yaml:"config"`
+		}
+		if yaml.Unmarshal(data, &pkg) == nil && pkg.Config.CzCustomizable.Config != "" {
+			paths = append(paths, filepath.Join(dir, pkg.Config.CzCustomizable.Config))
+		}
+	}
+	for _, name := range czCustomizableFiles {
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	return paths
+}
+
+// readCzCustomizableConfig has node evaluate the configuration, as it is
+// always JavaScript.
+func readCzCustomizableConfig(path string) (czCustomizableConfig, bool) {
+	var c czCustomizableConfig
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return c, false
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return c, false
+	}
+
+	cmd := exec.Command("node", "-e", "process.stdout.write(JSON.stringify(require(process.argv[1])))", abs)
+	data, err := cmd.Output()
+	if err != nil {
+		return c, false
+	}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return c, false
+	}
+	return c, true
+}
+
+// choiceDescription strips the type from the description shown for a
+// choice, e.g. "feat:     A new feature" becomes "A new feature".
+func choiceDescription(choice czChoice) string {
+	description := strings.TrimSpace(strings.TrimPrefix(choice.Name, choice.Value))
+	description = strings.TrimSpace(strings.TrimPrefix(description, ":"))
+	if description == "" {
+		return choice.Name
+	}
+	return description
+}
+
+// apply maps the configuration onto cometary's own, returning warnings
+// about the parts that cannot be. The messages are always put together in
+// the conventional form, so a message_template is rejected and the questions
+// only provide the prefixes and scopes.
+func (cz commitizenConfig) apply(c *config) []string {
+	if cz.customizable != nil {
+		cz.customizable.apply(c)
+		return nil
+	}
+
+	var warnings []string
+
+	bumpMap := conventionalBumpMap
+	if cz.Name == "cz_customize" {
+		bumpMap = cz.Customize.BumpMap
+		foundPrefixes := false
+		for _, q := range cz.Customize.Questions {
+			if len(q.Choices) == 0 {
+				continue
+			}
+			switch {
+			case q.Name == "scope":
+				c.Scopes = nil
+				for _, choice := range q.Choices {
+					c.Scopes = append(c.Scopes, choice.Value)
+				}
+			// The first other question with choices is the one for the type
+			case !foundPrefixes:
+				foundPrefixes = true
+				var prefixes []prefix
+				for _, choice := range q.Choices {
+					prefixes = append(prefixes, importedPrefix(c, choice.Value, choiceDescription(choice)))
+				}
+				c.Prefixes = prefixes
+			}
+		}
+		if cz.Customize.MessageTemplate != "" {
+			warnings = append(warnings, "the message_template of cz_customize is not supported, messages are put together as <type>(<scope>): <message>")
+		}
+		if cz.Customize.SchemaPattern != "" {
+			// Anchored at the start like Python's re.match
+			pattern := "^(?:" + cz.Customize.SchemaPattern + ")"
+			if _, err := regexp.Compile(pattern); err != nil {
+				warnings = append(warnings, fmt.Sprintf("the schema_pattern of cz_customize is not used, as it is not supported: %s", err))
+			} else {
+				c.Rules.HeaderPattern = stringRule{Severity: ruleError, Value: pattern}
+			}
+		}
+	}
+
+	// Copied as the prefixes may still be the defaults. The bump_map of
+	// cz_customize decides the bumps, while the default one only fills in
+	// those not configured
+	c.Prefixes = append([]prefix(nil), c.Prefixes...)
+	for i, p := range c.Prefixes {
+		if p.Bump != "" && cz.Name != "cz_customize" {
+			continue
+		}
+		if bump := bumpFromMap(bumpMap, p.T); bump != "" {
+			c.Prefixes[i].Bump = bump
+		}
+		if section, ok := cz.Customize.ChangeTypeMap[p.T]; ok {
+			c.Prefixes[i].Section = section
+		}
+	}
+
+	if cz.TagFormat != "" {
+		format := strings.NewReplacer("${version}", "{version}", "$version", "{version}").Replace(cz.TagFormat)
+		// Formats made up of the individual parts of the version are not
+		// supported
+		if strings.Contains(format, "{version}") && !strings.Contains(format, "$") {
+			c.TagFormat = format
+		}
+	}
+
+	for _, f := range cz.VersionFiles {
+		path, pattern, _ := strings.Cut(f, ":")
+		c.VersionFiles = append(c.VersionFiles, versionFile{Path: path, Pattern: pattern})
+	}
+	return warnings
+}
+
+// bumpFromMap returns the part of the version the prefix bumps according to
+// a bump map, where the keys are patterns and the greatest bump wins.
+func bumpFromMap(bumpMap map[string]string, title string) string {
+	patterns := make([]string, 0, len(bumpMap))
+	for pattern := range bumpMap {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	bump := ""
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil || !re.MatchString(title) {
+			continue
+		}
+		switch value := strings.ToLower(bumpMap[pattern]); {
+		case value == "major", value == "minor" && bump != "major", value == "patch" && bump == "":
+			bump = value
+		}
+	}
+	return bump
+}
+
+func (cz czCustomizableConfig) apply(c *config) {
+	if len(cz.Types) > 0 {
+		var prefixes []prefix
+		for _, t := range cz.Types {
+			p := importedPrefix(c, t.Value, choiceDescription(t))
+			if p.Bump == "" {
+				p.Bump = bumpFromMap(conventionalBumpMap, p.T)
+			}
+			prefixes = append(prefixes, p)
+		}
+		c.Prefixes = prefixes
+	}
+
+	// Scopes are either names or objects with a name
+	if len(cz.Scopes) > 0 {
+		c.Scopes = nil
+		for _, s := range cz.Scopes {
+			switch v := s.(type) {
+			case string:
+				c.Scopes = append(c.Scopes, v)
+			case map[string]interface{}:
+				if name, ok := v["name"].(string); ok {
+					c.Scopes = append(c.Scopes, name)
+				}
+			}
+		}
+	}
+
+	if cz.SubjectLimit > 0 {
+		c.CommitInputCharLimit = cz.SubjectLimit
+	}
+	if cz.UpperCaseSubject {
+		c.Rules.SubjectCase = stringRule{Severity: ruleError, Value: "upper"}
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// commitlintFiles are the places commitlint looks for its configuration, in
+// the same order. The package.json file is only used if it has a
+// "commitlint" key.
+var commitlintFiles = []string{
+	"package.json",
+	".commitlintrc",
+	".commitlintrc.json",
+	".commitlintrc.yaml",
+	".commitlintrc.yml",
+	".commitlintrc.js",
+	".commitlintrc.cjs",
+	".commitlintrc.mjs",
+	".commitlintrc.ts",
+	".commitlintrc.cts",
+	"commitlint.config.js",
+	"commitlint.config.cjs",
+	"commitlint.config.mjs",
+	"commitlint.config.ts",
+	"commitlint.config.cts",
+}
+
+// conventionalRules are the rules of @commitlint/config-conventional, which
+// cannot be read from node_modules as it may not be installed.
+var conventionalRules = map[string]interface{}{
+	"body-leading-blank":     []interface{}{1, "always"},
+	"body-max-line-length":   []interface{}{2, "always", 100},
+	"footer-leading-blank":   []interface{}{1, "always"},
+	"footer-max-line-length": []interface{}{2, "always", 100},
+	"header-max-length":      []interface{}{2, "always", 100},
+	"subject-case":           []interface{}{2, "never", []interface{}{"sentence-case", "start-case", "pascal-case", "upper-case"}},
+	"subject-empty":          []interface{}{2, "never"},
+	"subject-full-stop":      []interface{}{2, "never", "."},
+	"type-case":              []interface{}{2, "always", "lower-case"},
+	"type-empty":             []interface{}{2, "never"},
+	"type-enum": []interface{}{2, "always", []interface{}{
+		"build", "chore", "ci", "docs", "feat", "fix", "perf", "refactor", "revert", "style", "test",
+	}},
+}
+
+// scopeCasePatterns translate the cases commitlint knows of into a pattern
+// for the scope-pattern rule.
+var scopeCasePatterns = map[string]string{
+	"lower-case":  "[^A-Z]*",
+	"upper-case":  "[^a-z]*",
+	"kebab-case":  "[a-z0-9]+(-[a-z0-9]+)*",
+	"snake-case":  "[a-z0-9]+(_[a-z0-9]+)*",
+	"camel-case":  "[a-z][a-zA-Z0-9]*",
+	"pascal-case": "[A-Z][a-zA-Z0-9]*",
+}
+
+type commitlintConfig struct {
+	Extends interface{}            `yaml:"extends"`
+	Rules   map[string]interface{} `yaml:"rules"`
+	Prompt  struct {
+		Questions struct {
+			Type struct {
+				Enum map[string]struct {
+					Description string `yaml:"description"`
+				} `yaml:"enum"`
+			} `yaml:"type"`
+		} `yaml:"questions"`
+	} `yaml:"prompt"`
+}
+
+// commitlintRule is a rule in the form [level, applicable, value], where the
+// level is 0 for off, 1 for a warning and 2 for an error.
+type commitlintRule struct {
+	level  int
+	always bool
+	value  interface{}
+}
+
+func (r commitlintRule) severity() string {
+	switch r.level {
+	case 1:
+		return ruleWarning
+	case 2:
+		return ruleError
+	default:
+		return "off"
+	}
+}
+
+func (r commitlintRule) number() (int, bool) {
+	n, ok := r.value.(int)
+	return n, ok
+}
+
+func (r commitlintRule) list() []string {
+	switch v := r.value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func parseCommitlintRule(v interface{}) (commitlintRule, bool) {
+	values, ok := v.([]interface{})
+	if !ok || len(values) < 1 {
+		return commitlintRule{}, false
+	}
+
+	var r commitlintRule
+	switch level := values[0].(type) {
+	case int:
+		r.level = level
+	case float64:
+		r.level = int(level)
+	default:
+		return commitlintRule{}, false
+	}
+	r.always = true
+	if len(values) > 1 {
+		r.always = values[1] != "never"
+	}
+	if len(values) > 2 {
+		r.value = values[2]
+		// Numbers from JSON printed by node are decoded as floats
+		if f, ok := r.value.(float64); ok {
+			r.value = int(f)
+		}
+	}
+	return r, true
+}
+
+// findCommitlintConfig looks for a commitlint configuration in the current
+// directory and then at the root of the repository. JavaScript and
+// TypeScript ones are only evaluated when allowed, as that runs code from
+// the repository.
+func findCommitlintConfig(evaluateJS bool) (commitlintConfig, bool) {
+	dirs := []string{"."}
+	if root, err := repoRoot(); err == nil {
+		dirs = append(dirs, root)
+	}
+
+	for _, dir := range dirs {
+		for _, name := range commitlintFiles {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			if c, ok := readCommitlintConfig(path, evaluateJS); ok {
+				return c, true
+			}
+		}
+	}
+	return commitlintConfig{}, false
+}
+
+// readCommitlintConfig reads a JSON or YAML configuration directly, and has
+// node evaluate a JavaScript or TypeScript one if allowed and installed.
+func readCommitlintConfig(path string, evaluateJS bool) (commitlintConfig, bool) {
+	var c commitlintConfig
+	var data []byte
+	var err error
+
+	switch filepath.Ext(path) {
+	case ".js", ".cjs", ".mjs", ".ts", ".cts":
+		if !evaluateJS {
+			return c, false
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return c, false
+		}
+		cmd := exec.Command(
+			"node", "-e",
+			"import(require('url').pathToFileURL(process.argv[1]).href)"+
+				".then(m => process.stdout.write(JSON.stringify(m.default ?? m)))",
+			abs,
+		)
+		data, err = cmd.Output()
+		if err != nil {
+			return c, false
+		}
+	default:
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return c, false
+		}
+	}
+
+	if filepath.Base(path) == "package.json" {
+		var pkg struct {
+			Commitlint *commitlintConfig `yaml:"commitlint"`
+		}
+		// JSON is valid YAML, so a single parser covers every format
+		if err := yaml.Unmarshal(data, &pkg); err != nil || pkg.Commitlint == nil {
+			return c, false
+		}
+		return *pkg.Commitlint, true
+	}
+
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return c, false
+	}
+	return c, true
+}
+
+func (cl commitlintConfig) extends(name string) bool {
+	switch v := cl.Extends.(type) {
+	case string:
+		return v == name
+	case []interface{}:
+		for _, e := range v {
+			if e == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// apply maps the rules onto the configuration. Only the rules that are
+// present change anything, and rules without an equivalent are ignored.
+func (cl commitlintConfig) apply(c *config) {
+	all := make(map[string]interface{})
+	if cl.extends("@commitlint/config-conventional") {
+		for name, v := range conventionalRules {
+			all[name] = v
+		}
+	}
+	for name, v := range cl.Rules {
+		all[name] = v
+	}
+
+	// Sorted so that rules touching the same setting apply in a set order
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r, ok := parseCommitlintRule(all[name])
+		if !ok {
+			continue
+		}
+		cl.applyRule(c, name, r)
+	}
+}
+
+func (cl commitlintConfig) applyRule(c *config, name string, r commitlintRule) {
+	switch name {
+	case "type-enum":
+		if r.level == 0 || !r.always || len(r.list()) == 0 {
+			return
+		}
+		var prefixes []prefix
+		for _, t := range r.list() {
+			prefixes = append(prefixes, importedPrefix(c, t, cl.typeDescription(t)))
+		}
+		c.Prefixes = prefixes
+	case "scope-enum":
+		if r.level > 0 && r.always && len(r.list()) > 0 {
+			c.Scopes = r.list()
+		}
+	case "header-max-length":
+		// Not the total input limit, as that would override the other limits
+		if n, ok := r.number(); ok {
+			c.Rules.SubjectLineMaxLength = intRule{Severity: r.severity(), Value: n}
+		}
+	case "scope-max-length":
+		if n, ok := r.number(); ok && r.level > 0 {
+			c.ScopeInputCharLimit = n
+		}
+	case "subject-max-length":
+		if n, ok := r.number(); ok && r.level > 0 {
+			c.CommitInputCharLimit = n
+		}
+	case "body-max-line-length":
+		if n, ok := r.number(); ok {
+			c.Rules.BodyLineMaxLength = intRule{Severity: r.severity(), Value: n}
+		}
+	case "body-leading-blank":
+		if r.always {
+			c.Rules.BlankLineAfterSubject = rule{Severity: r.severity()}
+		}
+	case "subject-full-stop":
+		if !r.always && contains(r.list(), ".") {
+			c.Rules.NoTrailingPeriod = rule{Severity: r.severity()}
+		}
+	case "subject-case":
+		if value := subjectCase(r); value != "" {
+			c.Rules.SubjectCase = stringRule{Severity: r.severity(), Value: value}
+		}
+	case "scope-case":
+		if !r.always {
+			return
+		}
+		for _, s := range r.list() {
+			if pattern, ok := scopeCasePatterns[s]; ok {
+				c.Rules.ScopePattern = stringRule{Severity: r.severity(), Value: pattern}
+				return
+			}
+		}
+	case "trailer-exists", "signed-off-by":
+		if !r.always || r.level == 0 {
+			return
+		}
+		token := "Signed-off-by"
+		if values := r.list(); len(values) > 0 {
+			token = strings.TrimSuffix(strings.TrimSpace(values[0]), ":")
+		}
+		if !contains(c.Rules.RequiredFooters.Value, token) {
+			c.Rules.RequiredFooters.Value = append(c.Rules.RequiredFooters.Value, token)
+		}
+		c.Rules.RequiredFooters.Severity = r.severity()
+		if token == "Signed-off-by" {
+			c.SignOffCommits = true
+		}
+	}
+}
+
+// subjectCase returns the case of the first letter of the subject the rule
+// implies, if any. Only the first letter is checked by the subject-case rule,
+// which covers the cases commonly used.
+func subjectCase(r commitlintRule) string {
+	upper := false
+	lower := false
+	for _, s := range r.list() {
+		switch s {
+		case "sentence-case", "start-case", "pascal-case", "upper-case":
+			upper = true
+		case "lower-case", "camel-case", "kebab-case", "snake-case":
+			lower = true
+		}
+	}
+
+	switch {
+	case r.always && lower && !upper, !r.always && upper && !lower:
+		return "lower"
+	case r.always && upper && !lower, !r.always && lower && !upper:
+		return "upper"
+	default:
+		return ""
+	}
+}
+
+// typeDescription takes the description of a type from the prompt settings,
+// if there is one.
+func (cl commitlintConfig) typeDescription(t string) string {
+	return cl.Prompt.Questions.Type.Enum[t].Description
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	formatTable     = "table"
+	complianceLimit = 10
+)
+
+// complianceGroup is the number of commits in a group, e.g. those of one
+// author, and how many of them follow the conventions.
+type complianceGroup struct {
+	Name       string  `json:"name"`
+	Commits    int     `json:"commits"`
+	Conforming int     `json:"conforming"`
+	Percentage float64 `json:"percentage"`
+}
+
+func (g *complianceGroup) add(conforming bool) {
+	g.Commits++
+	if conforming {
+		g.Conforming++
+	}
+	g.Percentage = percentage(g.Conforming, g.Commits)
+}
+
+type complianceCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type complianceViolation struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Count    int    `json:"count"`
+}
+
+// complianceReport scores the commits in a range against the configuration.
+// Messages generated by git are skipped rather than scored.
+type complianceReport struct {
+	Range      string                `json:"range"`
+	Commits    int                   `json:"commits"`
+	Conforming int                   `json:"conforming"`
+	Percentage float64               `json:"percentage"`
+	Skipped    int                   `json:"skipped"`
+	Authors    []complianceGroup     `json:"authors"`
+	Months     []complianceGroup     `json:"months"`
+	Violations []complianceViolation `json:"violations"`
+	Types      []complianceCount     `json:"types"`
+	Scopes     []complianceCount     `json:"scopes"`
+}
+
+func percentage(part int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	// Rounded to one decimal
+	return math.Round(float64(part)*1000/float64(total)) / 10
+}
+
+// topCounts returns the counts, highest first, limited to the given number.
+func topCounts(counts map[string]int, limit int) []complianceCount {
+	top := make([]complianceCount, 0, len(counts))
+	for name, count := range counts {
+		top = append(top, complianceCount{Name: name, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Name < top[j].Name
+	})
+	if limit > 0 && len(top) > limit {
+		top = top[:limit]
+	}
+	return top
+}
+
+func scoreCompliance(c *config, revisions string, limit int) (complianceReport, error) {
+	report := complianceReport{Range: revisions}
+	commits, err := commitsInRange(revisions)
+	if err != nil {
+		return report, err
+	}
+
+	authors := make(map[string]*complianceGroup)
+	var authorOrder []string
+	months := make(map[string]*complianceGroup)
+	violations := make(map[complianceViolation]int)
+	types := make(map[string]int)
+	scopes := make(map[string]int)
+	for _, commit := range commits {
+		msg := parseCommitMessage(commit.message)
+		if skipLinting(msg.Header()) {
+			report.Skipped++
+			continue
+		}
+
+		// A message conforms when there are at most warnings, and each rule
+		// is counted once per commit
+		conforming := true
+		seen := make(map[complianceViolation]bool)
+		for _, d := range lintMessage(c, commit.message) {
+			if d.Severity == severityError {
+				conforming = false
+			}
+			v := complianceViolation{Rule: d.Rule, Severity: d.Severity}
+			if !seen[v] {
+				seen[v] = true
+				violations[v]++
+			}
+		}
+
+		report.Commits++
+		if conforming {
+			report.Conforming++
+		}
+
+		// Authors are told apart by email, under the name they used last
+		key := strings.ToLower(commit.email)
+		if _, ok := authors[key]; !ok {
+			authors[key] = &complianceGroup{Name: commit.author}
+			authorOrder = append(authorOrder, key)
+		}
+		authors[key].add(conforming)
+
+		month := commit.date.Format("2006-01")
+		if _, ok := months[month]; !ok {
+			months[month] = &complianceGroup{Name: month}
+		}
+		months[month].add(conforming)
+
+		if msg.Prefix != "" {
+			types[msg.Prefix]++
+		}
+		if msg.Scope != "" {
+			scopes[msg.Scope]++
+		}
+	}
+	report.Percentage = percentage(report.Conforming, report.Commits)
+
+	for _, key := range authorOrder {
+		report.Authors = append(report.Authors, *authors[key])
+	}
+	sort.SliceStable(report.Authors, func(i, j int) bool {
+		return report.Authors[i].Commits > report.Authors[j].Commits
+	})
+	for _, g := range months {
+		report.Months = append(report.Months, *g)
+	}
+	sort.Slice(report.Months, func(i, j int) bool {
+		return report.Months[i].Name < report.Months[j].Name
+	})
+
+	for v, count := range violations {
+		v.Count = count
+		report.Violations = append(report.Violations, v)
+	}
+	sort.Slice(report.Violations, func(i, j int) bool {
+		a, b := report.Violations[i], report.Violations[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Rule+a.Severity < b.Rule+b.Severity
+	})
+	if limit > 0 && len(report.Violations) > limit {
+		report.Violations = report.Violations[:limit]
+	}
+	report.Types = topCounts(types, limit)
+	report.Scopes = topCounts(scopes, limit)
+	return report, nil
+}
+
+func writeComplianceGroups(w io.Writer, title string, groups []complianceGroup) {
+	fmt.Fprintf(w, "\n%s\tCOMMITS\tCONFORMING\t%%\n", title)
+	for _, g := range groups {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\n", g.Name, g.Commits, g.Conforming, g.Percentage)
+	}
+}
+
+func writeComplianceCounts(w io.Writer, title string, counts []complianceCount) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s\tCOMMITS\n", title)
+	for _, c := range counts {
+		fmt.Fprintf(w, "%s\t%d\n", c.Name, c.Count)
+	}
+}
+
+func writeComplianceTable(out io.Writer, report complianceReport) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "%d of %d commits in %s follow the conventions (%.1f%%)", report.Conforming, report.Commits, report.Range, report.Percentage)
+	if report.Skipped > 0 {
+		fmt.Fprintf(w, ", %d generated by git skipped", report.Skipped)
+	}
+	fmt.Fprintln(w)
+	if report.Commits == 0 {
+		return w.Flush()
+	}
+
+	writeComplianceGroups(w, "AUTHOR", report.Authors)
+	writeComplianceGroups(w, "MONTH", report.Months)
+	if len(report.Violations) > 0 {
+		fmt.Fprintf(w, "\nRULE\tSEVERITY\tCOMMITS\n")
+		for _, v := range report.Violations {
+			fmt.Fprintf(w, "%s\t%s\t%d\n", v.Rule, v.Severity, v.Count)
+		}
+	}
+	writeComplianceCounts(w, "TYPE", report.Types)
+	writeComplianceCounts(w, "SCOPE", report.Scopes)
+	return w.Flush()
+}
+
+func runCompliance(c *config, args []string) error {
+	flags := flag.NewFlagSet("compliance", flag.ExitOnError)
+	revisions := flags.String("range", "HEAD", "score the messages of the commits in a revision range")
+	format := flags.String("format", formatTable, "output format: table or json")
+	limit := flags.Int("top", complianceLimit, "number of violations, types and scopes to list, 0 for all")
+	_ = flags.Parse(args)
+
+	if *format != formatTable && *format != formatJSON {
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+	if err := findGitDir(); err != nil {
+		return err
+	}
+
+	report, err := scoreCompliance(c, *revisions, *limit)
+	if err != nil {
+		return err
+	}
+	if *format == formatJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return writeComplianceTable(os.Stdout, report)
+}
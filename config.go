@@ -2,28 +2,60 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 )
 
 type prefix struct {
-	T string `json:"title"`
-	D string `json:"description"`
+	T                    string `json:"title"`
+	D                    string `json:"description"`
+	Bump                 string `json:"bump"`
+	Section              string `json:"section"`
+	ExcludeFromChangelog bool   `json:"excludeFromChangelog"`
+}
+
+// versionFile is a file holding the version, where the pattern, if any,
+// matches the line to update.
+type versionFile struct {
+	Path    string `json:"path"`
+	Pattern string `json:"pattern"`
+}
+
+// releasePackage is a part of a monorepo that is versioned on its own, with
+// tags such as services/billing/v1.4.0.
+type releasePackage struct {
+	Path         string        `json:"path"`
+	Scopes       []string      `json:"scopes"`
+	VersionFiles []versionFile `json:"versionFiles"`
 }
 
 type config struct {
-	Prefixes              []prefix `json:"prefixes"`
-	SignOffCommits        bool     `json:"signOffCommits"`
-	ScopeInputCharLimit   int      `json:"scopeInputCharLimit"`
-	CommitInputCharLimit  int      `json:"commitInputCharLimit"`
-	TotalInputCharLimit   int      `json:"totalInputCharLimit"`
-	ScopeCompletionOrder  string   `json:"scopeCompletionOrder"`
-	FindAllCommitMessages bool     `json:"findAllCommitMessages"`
-	StoreRuntime          bool     `json:"storeRuntime"`
-	ShowRuntime           bool     `json:"showRuntime"`
-	ShowStats             bool     `json:"showStats"`
-	ShowStatsFormat       string   `json:"showStatsFormat"`
-	SessionStatAsSeconds  bool     `json:"sessionStatAsSeconds"`
+	Prefixes              []prefix         `json:"prefixes"`
+	PrefixRules           []prefixRule     `json:"prefixRules"`
+	Scopes                []string         `json:"scopes"`
+	SignOffCommits        bool             `json:"signOffCommits"`
+	ScopeInputCharLimit   int              `json:"scopeInputCharLimit"`
+	CommitInputCharLimit  int              `json:"commitInputCharLimit"`
+	TotalInputCharLimit   int              `json:"totalInputCharLimit"`
+	ScopeCompletionOrder  string           `json:"scopeCompletionOrder"`
+	FindAllCommitMessages bool             `json:"findAllCommitMessages"`
+	StoreRuntime          bool             `json:"storeRuntime"`
+	ShowRuntime           bool             `json:"showRuntime"`
+	ShowStats             bool             `json:"showStats"`
+	ShowStatsFormat       string           `json:"showStatsFormat"`
+	SessionStatAsSeconds  bool             `json:"sessionStatAsSeconds"`
+	PrepareRevert         bool             `json:"prepareRevert"`
+	ProtectedBranches     []string         `json:"protectedBranches"`
+	TagFormat             string           `json:"tagFormat"`
+	ChangelogStyle        string           `json:"changelogStyle"`
+	ChangelogFile         string           `json:"changelogFile"`
+	CommitURL             string           `json:"commitURL"`
+	IssueURL              string           `json:"issueURL"`
+	VersionFiles          []versionFile    `json:"versionFiles"`
+	Packages              []releasePackage `json:"packages"`
+	Rules                 rules            `json:"rules"`
+	EvaluateJSConfig      bool             `json:"evaluateJSConfig"`
 }
 
 func (i prefix) Title() string       { return i.T }
@@ -44,16 +76,19 @@ var defaultPrefixes = []prefix{
 		D: "Documentation changes only",
 	},
 	{
-		T: "test",
-		D: "Adding missing tests or correcting existing tests",
+		T:                    "test",
+		D:                    "Adding missing tests or correcting existing tests",
+		ExcludeFromChangelog: true,
 	},
 	{
-		T: "build",
-		D: "Changes that affect the build system",
+		T:                    "build",
+		D:                    "Changes that affect the build system",
+		ExcludeFromChangelog: true,
 	},
 	{
-		T: "ci",
-		D: "Changes to CI configuration files and scripts",
+		T:                    "ci",
+		D:                    "Changes to CI configuration files and scripts",
+		ExcludeFromChangelog: true,
 	},
 	{
 		T: "perf",
@@ -68,18 +103,71 @@ var defaultPrefixes = []prefix{
 		D: "Reverts a previous change",
 	},
 	{
-		T: "style",
-		D: "Changes that do not affect the meaning of the code (white-space, formatting, missing semi-colons, etc)",
+		T:                    "style",
+		D:                    "Changes that do not affect the meaning of the code (white-space, formatting, missing semi-colons, etc)",
+		ExcludeFromChangelog: true,
 	},
 	{
-		T: "chore",
-		D: "A minor change which does not fit into any other category",
+		T:                    "chore",
+		D:                    "A minor change which does not fit into any other category",
+		ExcludeFromChangelog: true,
 	},
 }
 
 const applicationName = "cometary"
 
-func loadConfig() *config {
+// defaultTagFormat is the name of a release tag, where {version} stands for
+// the version without any prefix.
+const defaultTagFormat = "v{version}"
+
+// loadConfig reads the configuration file and then, unless only that is
+// needed, takes the prefixes, scopes, limits and rules from any Commitizen
+// or commitlint configuration in the repository, so that these do not need
+// to be kept in sync.
+func loadConfig(importConfigs bool) *config {
+	c := readConfig()
+	if !importConfigs {
+		return c
+	}
+	if cz, ok := findCommitizenConfig(c.EvaluateJSConfig); ok {
+		for _, warning := range cz.apply(c) {
+			_, _ = fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+		}
+	}
+	if cl, ok := findCommitlintConfig(c.EvaluateJSConfig); ok {
+		cl.apply(c)
+	}
+	return c
+}
+
+// importedPrefix returns the prefix for a type taken from the configuration
+// of another tool, keeping the settings of the configured prefix of the same
+// name, or otherwise of the default one. The description is only replaced
+// when one is given.
+func importedPrefix(c *config, title string, description string) prefix {
+	p := prefix{T: title}
+	found := false
+	for _, existing := range c.Prefixes {
+		if existing.T == title {
+			p, found = existing, true
+			break
+		}
+	}
+	if !found {
+		for _, d := range defaultPrefixes {
+			if d.T == title {
+				p = d
+				break
+			}
+		}
+	}
+	if description != "" {
+		p.D = description
+	}
+	return p
+}
+
+func readConfig() *config {
 	nonXdgConfigFile := ".comet.json"
 
 	// Check for configuration file local to current directory
@@ -109,6 +197,7 @@ func loadConfig() *config {
 func newConfig() *config {
 	return &config{
 		Prefixes:              defaultPrefixes,
+		PrefixRules:           defaultPrefixRules,
 		SignOffCommits:        false,
 		ScopeInputCharLimit:   16,
 		CommitInputCharLimit:  100,
@@ -120,6 +209,9 @@ func newConfig() *config {
 		ShowStats:             false,
 		ShowStatsFormat:       "seconds",
 		SessionStatAsSeconds:  true,
+		PrepareRevert:         false,
+		ProtectedBranches:     defaultProtectedBranches,
+		TagFormat:             defaultTagFormat,
 	}
 }
 
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const fixupCommitsLimit = 100
+
+var (
+	fixupListText = "Which commit are you fixing up?"
+	fixupKindText = "How should it be folded in?"
+	fixupKinds    = []prefix{
+		{
+			T: "fixup",
+			D: "Create a 'fixup!' commit that keeps the original message",
+		},
+		{
+			T: "squash",
+			D: "Create a 'squash!' commit whose message is appended to the original",
+		},
+		{
+			T: "amend",
+			D: "Create an 'amend!' commit whose message replaces the original",
+		},
+	}
+)
+
+type fixupModel struct {
+	chosenCommit       bool
+	chosenKind         bool
+	quitting           bool
+	target             commitEntry
+	kind               string
+	commitList         list.Model
+	kindList           list.Model
+	previousInputTexts string
+}
+
+func newFixupModel(commits []commitEntry) *fixupModel {
+	commitList := list.New(convertCommits(commits), commitDelegate{}, defaultWidth, listHeight)
+	commitList.Title = fixupListText
+	commitList.SetShowStatusBar(false)
+	commitList.Styles.Title = titleTextStyle
+	commitList.Styles.PaginationStyle = paginationStyle
+	commitList.Styles.HelpStyle = helpStyle
+
+	kindList := list.New(convertPrefixes(fixupKinds), itemDelegate{}, defaultWidth, len(fixupKinds)+4)
+	kindList.Title = fixupKindText
+	kindList.SetShowStatusBar(false)
+	kindList.SetFilteringEnabled(false)
+	kindList.SetShowPagination(false)
+	kindList.Styles.Title = titleTextStyle
+	kindList.Styles.HelpStyle = helpStyle
+
+	return &fixupModel{
+		commitList: commitList,
+		kindList:   kindList,
+	}
+}
+
+func (m *fixupModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *fixupModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok && msg.String() == "ctrl+c" {
+		m.quitting = true
+		return m, tea.Quit
+	}
+
+	var cmd tea.Cmd
+	switch {
+	case !m.chosenCommit:
+		if msg, ok := msg.(tea.KeyMsg); ok && msg.String() == "enter" && m.commitList.FilterState() != list.Filtering {
+			if c, ok := m.commitList.SelectedItem().(commitEntry); ok {
+				m.chosenCommit = true
+				m.target = c
+				m.previousInputTexts = fmt.Sprintf(
+					"\n%s %s\n",
+					fixupListText,
+					selectedItemStyle.Render(fmt.Sprintf("%s %s", c.shortSHA(), c.subject)),
+				)
+			}
+			return m, nil
+		}
+		m.commitList, cmd = m.commitList.Update(msg)
+	case !m.chosenKind:
+		if msg, ok := msg.(tea.KeyMsg); ok && msg.String() == "enter" {
+			if k, ok := m.kindList.SelectedItem().(prefix); ok {
+				m.chosenKind = true
+				m.kind = k.Title()
+				m.previousInputTexts = fmt.Sprintf(
+					"%s%s %s\n",
+					m.previousInputTexts,
+					fixupKindText,
+					selectedItemStyle.Render(m.kind),
+				)
+			}
+			return m, tea.Quit
+		}
+		m.kindList, cmd = m.kindList.Update(msg)
+	}
+	return m, cmd
+}
+
+func (m *fixupModel) View() string {
+	switch {
+	case m.quitting:
+		return quitTextStyle.Render("Aborted.\n")
+	case !m.chosenCommit:
+		return "\n" + m.commitList.View()
+	case !m.chosenKind:
+		return titleStyle.Render(m.previousInputTexts) + "\n" + m.kindList.View()
+	default:
+		return titleStyle.Render(fmt.Sprintf(
+			"%s\n---\n",
+			m.previousInputTexts,
+		))
+	}
+}
+
+func (m *fixupModel) Finished() bool {
+	return m.chosenKind
+}
+
+func runFixup(c *config, args []string) error {
+	flags := flag.NewFlagSet("fixup", flag.ExitOnError)
+	rebase := flags.Bool("autosquash", false, "run a non-interactive autosquash rebase afterwards")
+	_ = flags.Parse(args)
+
+	if err := findGitDir(); err != nil {
+		return err
+	}
+
+	if _, err := filesInStaging(); err != nil {
+		return err
+	}
+
+	commits, err := branchCommits(fixupCommitsLimit)
+	if err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		return fmt.Errorf("no commits to fix up")
+	}
+
+	m := newFixupModel(commits)
+	if _, err := tea.NewProgram(m).Run(); err != nil {
+		return err
+	}
+
+	fmt.Println("")
+	if !m.Finished() {
+		return fmt.Errorf("terminated")
+	}
+
+	if err := fixupCommit(m.kind, m.target.sha, c.SignOffCommits); err != nil {
+		return fmt.Errorf("error committing: %s", err)
+	}
+
+	if *rebase {
+		parents, err := parentsOfCommit(m.target.sha)
+		if err != nil {
+			return err
+		}
+		if err := autosquash(m.target.sha, len(parents) == 0); err != nil {
+			return fmt.Errorf("error rebasing: %s", err)
+		}
+	}
+	return nil
+}
@@ -1,12 +1,33 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
+var errNothingStaged = errors.New("no files added to staging area")
+
+type commitEntry struct {
+	sha     string
+	subject string
+}
+
+func (c commitEntry) Title() string       { return c.shortSHA() }
+func (c commitEntry) Description() string { return c.subject }
+func (c commitEntry) FilterValue() string { return c.sha + " " + c.subject }
+
+func (c commitEntry) shortSHA() string {
+	if len(c.sha) > 7 {
+		return c.sha[:7]
+	}
+	return c.sha
+}
+
 func filesInStaging() ([]string, error) {
 	cmd := exec.Command("git", "diff", "--no-ext-diff", "--cached", "--name-only")
 	output, err := cmd.CombinedOutput()
@@ -15,11 +36,56 @@ func filesInStaging() ([]string, error) {
 	}
 	lines := strings.TrimSpace(string(output))
 	if lines == "" {
-		return []string{}, fmt.Errorf("no files added to staging area")
+		return []string{}, errNothingStaged
 	}
 	return strings.Split(lines, "\n"), nil
 }
 
+// changedFile is a file with changes, along with its status as git status
+// --short shows it, e.g. " M" when modified and "M " once staged.
+type changedFile struct {
+	status string
+	path   string
+}
+
+func (f changedFile) FilterValue() string { return f.path }
+
+// staged reports whether any of the changes to the file are staged.
+func (f changedFile) staged() bool {
+	return f.status[0] != ' ' && f.status[0] != '?'
+}
+
+// patchable reports whether the changes to the file can be staged by hunk,
+// which is only the case for modifications.
+func (f changedFile) patchable() bool {
+	return strings.Trim(f.status, " M") == "" && f.status != "  "
+}
+
+// changedFiles returns the modified, deleted and untracked files, staged or
+// not, with paths relative to the root of the repository.
+func changedFiles() ([]changedFile, error) {
+	cmd := exec.Command("git", "status", "--porcelain", "-z", "--untracked-files=all")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return []changedFile{}, fmt.Errorf(string(output))
+	}
+
+	var files []changedFile
+	fields := strings.Split(string(output), "\x00")
+	for i := 0; i < len(fields); i++ {
+		entry := fields[i]
+		if len(entry) < 4 {
+			continue
+		}
+		// Renames and copies are followed by the path they came from
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++
+		}
+		files = append(files, changedFile{status: entry[:2], path: entry[3:]})
+	}
+	return files, nil
+}
+
 func findGitDir() error {
 	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
 	output, err := cmd.CombinedOutput()
@@ -34,18 +100,458 @@ func commit(msg string, body bool, signOff bool) error {
 	if len(os.Args) > 1 && os.Args[1] == "-m" {
 		gitArgs = os.Args[3:]
 	}
-	args := append([]string{
-		"commit", "-m", msg,
-	}, gitArgs...)
+	return commitWithArgs(msg, body, signOff, gitArgs)
+}
+
+// commitWithArgs passes the arguments to git commit last, so that they can
+// end with paths.
+func commitWithArgs(msg string, body bool, signOff bool, gitArgs []string) error {
+	args := []string{"commit", "-m", msg}
 	if body {
 		args = append(args, "-e")
 	}
 	if signOff {
 		args = append(args, "-s")
 	}
+	args = append(args, gitArgs...)
 	cmd := exec.Command("git", args...)
+	if body {
+		editor, err := gitEditor()
+		if err != nil {
+			return err
+		}
+		cmd.Env = append(os.Environ(), "GIT_EDITOR="+editor)
+	}
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
+
+func recentCommits(limit int, revs ...string) ([]commitEntry, error) {
+	args := append([]string{
+		"log", "--no-merges", "--pretty=format:%H%x09%s", fmt.Sprintf("--max-count=%d", limit),
+	}, revs...)
+	cmd := exec.Command("git", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return []commitEntry{}, fmt.Errorf(string(output))
+	}
+
+	var commits []commitEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		s := strings.SplitN(line, "\t", 2)
+		if len(s) != 2 {
+			continue
+		}
+		commits = append(commits, commitEntry{sha: s[0], subject: s[1]})
+	}
+	return commits, nil
+}
+
+func revertWorkingTree(sha string) error {
+	cmd := exec.Command("git", "revert", "--no-commit", sha)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// defaultBranch returns the branch that the remote HEAD points to, falling
+// back to the commonly used names if there is no remote.
+func defaultBranch() string {
+	cmd := exec.Command("git", "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD")
+	if output, err := cmd.Output(); err == nil {
+		return strings.TrimSpace(string(output))
+	}
+	for _, b := range []string{"main", "master", "origin/main", "origin/master"} {
+		cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", b)
+		if err := cmd.Run(); err == nil {
+			return b
+		}
+	}
+	return ""
+}
+
+func mergeBase(a, b string) (string, error) {
+	cmd := exec.Command("git", "merge-base", a, b)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf(string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func revParse(rev string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("unknown revision: %s", rev)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// branchCommits returns the commits on the current branch since it diverged
+// from the default branch, or simply the most recent ones when there is no
+// such divergence.
+func branchCommits(limit int) ([]commitEntry, error) {
+	if branch := defaultBranch(); branch != "" {
+		base, err := mergeBase("HEAD", branch)
+		head, _ := revParse("HEAD")
+		if err == nil && base != head {
+			return recentCommits(limit, base+"..HEAD")
+		}
+	}
+	return recentCommits(limit)
+}
+
+func fixupCommit(kind string, sha string, signOff bool) error {
+	args := []string{"commit"}
+	switch kind {
+	case "squash":
+		args = append(args, "--squash="+sha)
+	case "amend":
+		args = append(args, "--fixup=amend:"+sha)
+	default:
+		args = append(args, "--fixup="+sha)
+	}
+	if signOff {
+		args = append(args, "-s")
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func autosquash(sha string, root bool) error {
+	args := []string{"rebase", "--interactive", "--autosquash", "--autostash"}
+	if root {
+		args = append(args, "--root")
+	} else {
+		args = append(args, sha+"^")
+	}
+	cmd := exec.Command("git", args...)
+	// Accept the generated todo list as-is
+	cmd.Env = append(os.Environ(), "GIT_SEQUENCE_EDITOR=:")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func filesInCommit(rev string) ([]string, error) {
+	cmd := exec.Command("git", "diff-tree", "--no-commit-id", "--name-only", "-r", "--root", rev)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return []string{}, fmt.Errorf(string(output))
+	}
+	lines := strings.TrimSpace(string(output))
+	if lines == "" {
+		return []string{}, nil
+	}
+	return strings.Split(lines, "\n"), nil
+}
+
+func messageOfCommit(rev string) (string, error) {
+	cmd := exec.Command("git", "log", "-1", "--format=%B", rev)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf(string(output))
+	}
+	return string(output), nil
+}
+
+func parentsOfCommit(sha string) ([]string, error) {
+	cmd := exec.Command("git", "rev-list", "--parents", "-n", "1", sha)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return []string{}, fmt.Errorf(string(output))
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return []string{}, nil
+	}
+	return fields[1:], nil
+}
+
+// protectedBranchContaining returns the first remote-tracking branch named
+// after one of the protected branches that already contains the commit.
+func protectedBranchContaining(sha string, protected []string) (string, error) {
+	cmd := exec.Command("git", "branch", "--remotes", "--contains", sha, "--format=%(refname:short)")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf(string(output))
+	}
+	for _, ref := range strings.Fields(string(output)) {
+		_, name, ok := strings.Cut(ref, "/")
+		if !ok {
+			continue
+		}
+		for _, p := range protected {
+			if name == p {
+				return ref, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// editFile opens the file in the editor git itself would use.
+func editFile(path string) error {
+	editor, err := gitEditor()
+	if err != nil {
+		return err
+	}
+	return runEditor(editor, path)
+}
+
+// gitEditor returns the editor git would use, or the regular one when git is
+// set up to use "cometary edit", so that the prompts do not run again on a
+// message they have just put together.
+func gitEditor() (string, error) {
+	output, err := exec.Command("git", "var", "GIT_EDITOR").Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine editor")
+	}
+	editor := strings.TrimSpace(string(output))
+	if fields := strings.Fields(editor); len(fields) > 1 && filepath.Base(fields[0]) == applicationName && fields[1] == "edit" {
+		return regularEditor(), nil
+	}
+	return editor, nil
+}
+
+// runEditor runs the editor command through the shell the same way git does,
+// so that it can include arguments of its own.
+func runEditor(editor string, path string) error {
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// rewordCommit replaces the message of the commit with the contents of
+// msgFile. Commits other than HEAD are rewritten through a rebase whose todo
+// list is edited by this very program instead of an interactive editor.
+func rewordCommit(sha string, root bool, msgFile string, signOff bool) error {
+	amend := []string{"commit", "--amend", "--only", "--cleanup=strip", "-F", msgFile}
+	if signOff {
+		amend = append(amend, "-s")
+	}
+
+	if head, _ := revParse("HEAD"); head == sha {
+		cmd := exec.Command("git", amend...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	// Merges after the commit are recreated rather than flattened
+	args := []string{"rebase", "--interactive", "--autostash", "--rebase-merges"}
+	if root {
+		args = append(args, "--root")
+	} else {
+		args = append(args, sha+"^")
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(
+		os.Environ(),
+		fmt.Sprintf("GIT_SEQUENCE_EDITOR=%s sequence-editor %s %s %t", shellQuote(exe), sha, shellQuote(msgFile), signOff),
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+type loggedCommit struct {
+	sha     string
+	author  string
+	email   string
+	date    time.Time
+	message string
+}
+
+// commitsInRange returns the commits selected by the given revisions, newest
+// first, as git log would list them.
+func commitsInRange(revs ...string) ([]loggedCommit, error) {
+	args := append([]string{"log", "--format=%H%x1f%an%x1f%ae%x1f%aI%x1f%B%x1e"}, revs...)
+	cmd := exec.Command("git", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return []loggedCommit{}, fmt.Errorf(string(output))
+	}
+
+	var commits []loggedCommit
+	for _, record := range strings.Split(string(output), "\x1e") {
+		s := strings.SplitN(strings.TrimLeft(record, "\n"), "\x1f", 5)
+		if len(s) != 5 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, s[3])
+		commits = append(commits, loggedCommit{
+			sha:     s[0],
+			author:  s[1],
+			email:   s[2],
+			date:    date,
+			message: s[4],
+		})
+	}
+	return commits, nil
+}
+
+// gitConfig returns the value of the configuration key, or fallback if it is
+// not set.
+func gitConfig(key string, fallback string) string {
+	output, err := exec.Command("git", "config", "--get", key).Output()
+	if err != nil {
+		return fallback
+	}
+	if value := strings.TrimSpace(string(output)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func repoRoot() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf(string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// hooksDir returns the directory git runs hooks from, which takes
+// core.hooksPath into account.
+func hooksDir() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--git-path", "hooks")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf(string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// stagedIdentifiers returns the words making up the identifiers and paths
+// found in the staged changes.
+func stagedIdentifiers() map[string]bool {
+	cmd := exec.Command("git", "diff", "--no-ext-diff", "--cached", "--unified=0")
+	output, err := cmd.Output()
+	if err != nil {
+		return map[string]bool{}
+	}
+	return identifierWords(string(output))
+}
+
+// latestTag returns the most recent tag reachable from the revision, or an
+// empty string if there is none.
+func latestTag(rev string) string {
+	output, err := exec.Command("git", "describe", "--tags", "--abbrev=0", rev).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// isTag reports whether the name is that of a tag.
+func isTag(name string) bool {
+	return exec.Command("git", "rev-parse", "--verify", "--quiet", "refs/tags/"+name).Run() == nil
+}
+
+// mergedTags returns the tags reachable from the revision.
+func mergedTags(rev string) ([]string, error) {
+	cmd := exec.Command("git", "tag", "--merged", rev)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return []string{}, fmt.Errorf(string(output))
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// createTag creates an annotated tag at HEAD with the message kept as is, as
+// Markdown headings would otherwise be taken for comments.
+func createTag(name string, message string) error {
+	cmd := exec.Command("git", "tag", "--annotate", "--cleanup=verbatim", "--file=-", name)
+	cmd.Stdin = strings.NewReader(message)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf(string(output))
+	}
+	return nil
+}
+
+// diffOfFile returns the unstaged or staged changes to the file, with the
+// path relative to the root of the repository. The prefixes are set as git
+// apply expects them, whatever diff.noprefix and diff.mnemonicPrefix say.
+func diffOfFile(path string, cached bool) (string, error) {
+	args := []string{"diff", "--no-ext-diff", "--no-color", "--no-relative", "--src-prefix=a/", "--dst-prefix=b/"}
+	if cached {
+		args = append(args, "--cached")
+	}
+	cmd := exec.Command("git", append(args, "--", ":(top)"+path)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf(string(output))
+	}
+	return string(output), nil
+}
+
+// applyToIndex applies the patch to the staging area only, or takes it back
+// out when reversed. The line counts of hunks are worked out anew so that
+// patches made of some of the lines of a hunk apply.
+func applyToIndex(patch string, reverse bool) error {
+	args := []string{"apply", "--cached", "--recount"}
+	if reverse {
+		args = append(args, "--reverse")
+	}
+	// The paths in the patch are relative to the root
+	root, err := repoRoot()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("git", append(args, "-")...)
+	cmd.Dir = root
+	cmd.Stdin = strings.NewReader(patch)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf(string(output))
+	}
+	return nil
+}
+
+// indexTree returns the tree of what is staged, for putting the staging area
+// back as it was with restoreIndex.
+func indexTree() (string, error) {
+	cmd := exec.Command("git", "write-tree")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf(string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func restoreIndex(tree string) error {
+	cmd := exec.Command("git", "read-tree", tree)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf(string(output))
+	}
+	// Otherwise every file looks modified until the index is refreshed
+	_ = exec.Command("git", "update-index", "-q", "--refresh").Run()
+	return nil
+}
+
+func stageFiles(paths ...string) error {
+	args := append([]string{"add", "--"}, paths...)
+	cmd := exec.Command("git", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf(string(output))
+	}
+	return nil
+}
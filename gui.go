@@ -1,9 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os/exec"
+	"path/filepath"
 	"runtime/debug"
 	"sort"
 	"strconv"
@@ -18,8 +20,10 @@ import (
 )
 
 const (
-	defaultWidth = 40
-	listHeight   = 15
+	defaultWidth       = 40
+	listHeight         = 15
+	revertPrefix       = "revert"
+	revertCommitsLimit = 100
 )
 
 var (
@@ -33,18 +37,25 @@ var (
 	selectedItemStyle    = lipgloss.NewStyle().Foreground(selectedItemColors)
 	selectedItemPadded   = lipgloss.NewStyle().Foreground(selectedItemColors).PaddingLeft(2)
 	itemDescriptionStyle = lipgloss.NewStyle().PaddingLeft(2).Faint(true)
+	suggestedItemStyle   = lipgloss.NewStyle().Foreground(selectedItemColors).Faint(true)
 	paginationStyle      = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
 	helpStyle            = list.DefaultStyles().HelpStyle.PaddingLeft(4).PaddingBottom(1)
 	quitTextStyle        = lipgloss.NewStyle().Margin(1, 0, 2, 4)
+	errorStyle           = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#bf616a", Dark: "#bf616a"})
+	warningStyle         = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#d08770", Dark: "#ebcb8b"})
 	versionStyle         = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#9b9b9b", Dark: "#5c5c5c"}).Render
 	scopeInputText       = "What is the scope?"
 	msgInputText         = "What is the commit message?"
 	bodyInputText        = "Do you need to specify a body/footer?"
+	revertListText       = "Which commit are you reverting?"
+	fileListText         = "Which files are you committing?"
 	constrainInput       bool
 	totalInputCharLimit  int
 )
 
-type itemDelegate struct{}
+type itemDelegate struct {
+	suggested string
+}
 
 func (d itemDelegate) Height() int                             { return 1 }
 func (d itemDelegate) Spacing() int                            { return 0 }
@@ -64,26 +75,108 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 		output = itemStyle.Render(str)
 	}
 	output += itemDescriptionStyle.PaddingLeft(15 - len(str)).Render(i.Description())
+	if d.suggested != "" && i.Title() == d.suggested {
+		output += suggestedItemStyle.Render(" (suggested)")
+	}
+
+	_, _ = fmt.Fprint(w, output)
+}
+
+type commitDelegate struct{}
+
+func (d commitDelegate) Height() int                             { return 1 }
+func (d commitDelegate) Spacing() int                            { return 0 }
+func (d commitDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+func (d commitDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	c, ok := listItem.(commitEntry)
+	if !ok {
+		return
+	}
+
+	var output string
+	if index == m.Index() {
+		output = selectedItemPadded.Render("> " + c.Title())
+	} else {
+		output = itemStyle.Render(c.Title())
+	}
+	output += itemDescriptionStyle.Render(c.Description())
 
 	_, _ = fmt.Fprint(w, output)
 }
 
+// fileDelegate renders the files that can be staged, with the picked ones
+// checked.
+type fileDelegate struct {
+	picked map[string]bool
+}
+
+func (d fileDelegate) Height() int                             { return 1 }
+func (d fileDelegate) Spacing() int                            { return 0 }
+func (d fileDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+func (d fileDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	f, ok := listItem.(changedFile)
+	if !ok {
+		return
+	}
+
+	// Files with some of their changes staged are marked as such
+	check := "[ ]"
+	switch {
+	case d.picked[f.path]:
+		check = "[x]"
+	case f.staged():
+		check = "[~]"
+	}
+	str := fmt.Sprintf("%s %s %s", check, f.status, f.path)
+
+	var output string
+	if index == m.Index() {
+		output = selectedItemPadded.Render("> " + str)
+	} else {
+		output = itemStyle.Render(str)
+	}
+
+	_, _ = fmt.Fprint(w, output)
+}
+
+type filesStagedMsg struct {
+	files []string
+	err   error
+}
+
 type (
 	stagedFilesMsg    []string
 	commitMessagesMsg []string
+	recentCommitsMsg  []commitEntry
+	knownWordsMsg     map[string]bool
 )
 
 type model struct {
+	canPickFiles           bool
+	pickingFiles           bool
+	chosenFiles            bool
 	chosenPrefix           bool
+	chosenRevert           bool
 	chosenScope            bool
 	chosenMsg              bool
 	chosenBody             bool
 	specifyBody            bool
+	breaking               bool
 	prefix                 string
 	prefixDescription      string
 	scope                  string
 	msg                    string
+	body                   string
+	revertCommit           string
+	fileList               list.Model
+	pickedFiles            map[string]bool
+	optionalFiles          bool
+	fileError              string
+	patch                  *patchView
+	prefixes               []prefix
+	prefixRules            []prefixRule
 	prefixList             list.Model
+	revertList             list.Model
 	msgInput               textinput.Model
 	scopeInput             textinput.Model
 	ynInput                textinput.Model
@@ -95,6 +188,8 @@ type model struct {
 	stagedFiles            []string
 	scopeCompletionOrder   string
 	stagedFilePathSegments []string
+	scopes                 []string
+	rules                  rules
 	scopeInputIndex        int
 	commitSearchTerm       string
 	findAllCommitMessages  bool
@@ -103,6 +198,11 @@ type model struct {
 }
 
 func newModel(c *config, stagedFiles []string, commitSearchTerm string) *model {
+	rules := c.PrefixRules
+	if rules == nil {
+		rules = defaultPrefixRules
+	}
+
 	prefixes := convertPrefixes(c.Prefixes)
 	prefixList := list.New(prefixes, itemDelegate{}, defaultWidth, listHeight)
 	prefixList.Title = "What are you committing?"
@@ -112,6 +212,13 @@ func newModel(c *config, stagedFiles []string, commitSearchTerm string) *model {
 	prefixList.Styles.PaginationStyle = paginationStyle
 	prefixList.Styles.HelpStyle = helpStyle
 
+	revertList := list.New([]list.Item{}, commitDelegate{}, defaultWidth, listHeight)
+	revertList.Title = revertListText
+	revertList.SetShowStatusBar(false)
+	revertList.Styles.Title = titleTextStyle
+	revertList.Styles.PaginationStyle = paginationStyle
+	revertList.Styles.HelpStyle = helpStyle
+
 	scopeInput := textinput.New()
 	scopeInput.Placeholder = "Scope"
 
@@ -152,8 +259,11 @@ func newModel(c *config, stagedFiles []string, commitSearchTerm string) *model {
 	prefixList.AdditionalShortHelpKeys = func() []key.Binding { return bindings }
 	prefixList.AdditionalFullHelpKeys = func() []key.Binding { return bindings }
 
-	return &model{
+	m := &model{
+		prefixes:              c.Prefixes,
+		prefixRules:           rules,
 		prefixList:            prefixList,
+		revertList:            revertList,
 		scopeInput:            scopeInput,
 		msgInput:              commitInput,
 		ynInput:               bodyConfirmation,
@@ -161,9 +271,73 @@ func newModel(c *config, stagedFiles []string, commitSearchTerm string) *model {
 		totalInputCharLimit:   totalInputCharLimit,
 		stagedFiles:           stagedFiles,
 		scopeCompletionOrder:  c.ScopeCompletionOrder,
+		scopes:                c.Scopes,
+		rules:                 c.Rules,
 		commitSearchTerm:      commitSearchTerm,
 		findAllCommitMessages: c.FindAllCommitMessages,
 	}
+	m.suggestPrefix()
+	return m
+}
+
+// suggestPrefix selects the prefix that fits the staged files best.
+func (m *model) suggestPrefix() {
+	suggested := suggestPrefix(m.stagedFiles, m.prefixRules, m.prefixes)
+	m.prefixList.SetDelegate(itemDelegate{suggested: suggested})
+	for i, p := range m.prefixes {
+		if p.Title() == suggested {
+			m.prefixList.Select(i)
+			break
+		}
+	}
+}
+
+// AllowFilePicking lets the prefix list go back to picking the files to
+// stage, or hunks of them, when they have already been staged. Unless
+// optional, something has to stay staged.
+func (m *model) AllowFilePicking(optional bool) {
+	m.canPickFiles = true
+	m.optionalFiles = optional
+	bindings := []key.Binding{customKeys.Cycle, customKeys.Files}
+	m.prefixList.AdditionalShortHelpKeys = func() []key.Binding { return bindings }
+	m.prefixList.AdditionalFullHelpKeys = func() []key.Binding { return bindings }
+}
+
+// PickFiles adds a first step for choosing which of the changed files to
+// stage, for when nothing is staged yet. Unless optional, at least one file
+// has to be picked.
+func (m *model) PickFiles(files []changedFile, optional bool) {
+	m.AllowFilePicking(optional)
+	items := make([]list.Item, len(files))
+	for i, f := range files {
+		items[i] = f
+	}
+	m.pickedFiles = make(map[string]bool)
+	m.fileList = list.New(items, fileDelegate{picked: m.pickedFiles}, defaultWidth, listHeight)
+	m.fileList.Title = fileListText
+	m.fileList.SetShowStatusBar(false)
+	m.fileList.Styles.Title = titleTextStyle
+	m.fileList.Styles.PaginationStyle = paginationStyle
+	m.fileList.Styles.HelpStyle = helpStyle
+	bindings := []key.Binding{customKeys.Toggle, customKeys.ToggleAll, customKeys.Patch}
+	m.fileList.AdditionalShortHelpKeys = func() []key.Binding { return bindings }
+	m.fileList.AdditionalFullHelpKeys = func() []key.Binding { return bindings }
+	m.pickingFiles = true
+	m.chosenFiles = false
+}
+
+// StagedFiles returns the files that were staged, including those picked in
+// the first step.
+func (m *model) StagedFiles() []string {
+	return m.stagedFiles
+}
+
+func convertCommits(commits []commitEntry) []list.Item {
+	output := []list.Item{}
+	for _, c := range commits {
+		output = append(output, c)
+	}
+	return output
 }
 
 func convertPrefixes(prefixes []prefix) []list.Item {
@@ -178,6 +352,8 @@ func (m *model) Init() tea.Cmd {
 	return tea.Batch(
 		formUniquePaths(m.stagedFiles, m.scopeCompletionOrder),
 		findCommitMessages(m.commitSearchTerm, m.findAllCommitMessages),
+		findRecentCommits(revertCommitsLimit),
+		findKnownWords(m.rules.Spelling, m.stagedFiles),
 	)
 }
 
@@ -185,8 +361,12 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch {
+		case m.pickingFiles && !m.chosenFiles:
+			return m.updateFileList(msg)
 		case !m.chosenPrefix:
 			return m.updatePrefixList(msg)
+		case m.prefix == revertPrefix && !m.chosenRevert:
+			return m.updateRevertList(msg)
 		case !m.chosenScope:
 			return m.updateScopeInput(msg)
 		case !m.chosenMsg:
@@ -199,9 +379,31 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case stagedFilesMsg:
 		m.stagedFilePathSegments = msg
 		return m, nil
+	case filesStagedMsg:
+		if msg.err != nil {
+			m.fileError = msg.err.Error()
+			return m, nil
+		}
+		if len(msg.files) == 0 && !m.optionalFiles {
+			m.fileError = "Pick at least one file with space or stage some hunks with p"
+			return m, nil
+		}
+		m.chosenFiles = true
+		m.stagedFiles = msg.files
+		m.suggestPrefix()
+		return m, tea.Batch(
+			formUniquePaths(m.stagedFiles, m.scopeCompletionOrder),
+			findKnownWords(m.rules.Spelling, m.stagedFiles),
+		)
 	case commitMessagesMsg:
 		m.commitMessages = msg
 		return m, nil
+	case knownWordsMsg:
+		m.rules.Spelling.known = msg
+		return m, nil
+	case recentCommitsMsg:
+		cmd := m.revertList.SetItems(convertCommits(msg))
+		return m, cmd
 	}
 	return m, nil
 }
@@ -211,11 +413,116 @@ func (m *model) Finished() bool {
 }
 
 func (m *model) CommitMessage() (string, bool) {
-	prefix := m.prefix
-	if m.scope != "" {
-		prefix = fmt.Sprintf("%s(%s)", prefix, m.scope)
+	c := commitMessage{
+		Prefix:   m.prefix,
+		Scope:    m.scope,
+		Breaking: m.breaking,
+		Subject:  m.msg,
+		Body:     m.body,
+	}
+	return c.String(), m.specifyBody
+}
+
+// Prefill populates every step with the parts of an existing message, with
+// the body and footers being carried over as they are.
+func (m *model) Prefill(c commitMessage) {
+	for i, item := range m.prefixList.Items() {
+		if p, ok := item.(prefix); ok && p.Title() == c.Prefix {
+			m.prefixList.Select(i)
+			break
+		}
+	}
+	m.scopeInput.SetValue(c.Scope)
+	m.msgInput.SetValue(c.Subject)
+	m.breaking = c.Breaking
+	m.body = c.Trailer()
+}
+
+// RevertCommit returns the full hash of the commit chosen for reverting, if
+// any.
+func (m *model) RevertCommit() string {
+	return m.revertCommit
+}
+
+func (m *model) updateFileList(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok && msg.String() == "ctrl+c" {
+		m.quitting = true
+		return m, tea.Quit
+	}
+	if msg, ok := msg.(tea.KeyMsg); ok && m.patch != nil {
+		if !m.patch.update(msg) {
+			m.patch = nil
+			m.refreshFiles()
+		}
+		return m, nil
+	}
+
+	if msg, ok := msg.(tea.KeyMsg); ok && m.fileList.FilterState() != list.Filtering {
+		switch {
+		case key.Matches(msg, customKeys.Patch):
+			f, ok := m.fileList.SelectedItem().(changedFile)
+			if !ok {
+				return m, nil
+			}
+			if !f.patchable() {
+				m.fileError = "Only modified files can be staged by hunk"
+				return m, nil
+			}
+			patch, err := newPatchView(f)
+			if err != nil {
+				m.fileError = err.Error()
+				return m, nil
+			}
+			m.fileError = ""
+			m.patch = patch
+			return m, nil
+
+		case key.Matches(msg, customKeys.Toggle):
+			if f, ok := m.fileList.SelectedItem().(changedFile); ok {
+				m.pickedFiles[f.path] = !m.pickedFiles[f.path]
+			}
+			return m, nil
+
+		case key.Matches(msg, customKeys.ToggleAll):
+			// Everything shown is picked unless it already was
+			all := true
+			for _, item := range m.fileList.VisibleItems() {
+				all = all && m.pickedFiles[item.(changedFile).path]
+			}
+			for _, item := range m.fileList.VisibleItems() {
+				m.pickedFiles[item.(changedFile).path] = !all
+			}
+			return m, nil
+
+		case msg.String() == "enter":
+			var paths []string
+			for _, item := range m.fileList.Items() {
+				if f := item.(changedFile); m.pickedFiles[f.path] {
+					paths = append(paths, f.path)
+				}
+			}
+			m.fileError = ""
+			return m, stagePickedFiles(paths)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.fileList, cmd = m.fileList.Update(msg)
+	return m, cmd
+}
+
+// refreshFiles lists the changed files again after staging hunks of them.
+func (m *model) refreshFiles() {
+	files, err := changedFiles()
+	if err != nil {
+		m.fileError = err.Error()
+		return
+	}
+	items := make([]list.Item, len(files))
+	for i, f := range files {
+		items[i] = f
 	}
-	return fmt.Sprintf("%s: %s", prefix, m.msg), m.specifyBody
+	m.fileList.SetItems(items)
 }
 
 func (m *model) continueWithSelectedItem() {
@@ -229,7 +536,8 @@ func (m *model) continueWithSelectedItem() {
 			m.prefixList.Title,
 			selectedItemStyle.Render(fmt.Sprintf("%s: %s", m.prefix, m.prefixDescription)),
 		)
-		m.typed = len(m.prefix) + len("(): ")
+		// Leaving room for the parentheses around a scope
+		m.typed = m.headerLength("", "") + len("()")
 		m.scopeInput.Focus()
 	}
 }
@@ -241,6 +549,15 @@ func (m *model) updatePrefixList(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
+		if m.canPickFiles && key.Matches(msg, customKeys.Files) {
+			files, err := changedFiles()
+			m.PickFiles(files, m.optionalFiles)
+			if err != nil {
+				m.fileError = err.Error()
+			}
+			return m, nil
+		}
+
 		switch keypress := msg.String(); keypress {
 		case "ctrl+c":
 			m.quitting = true
@@ -271,14 +588,55 @@ func (m *model) updatePrefixList(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+func (m *model) continueWithSelectedCommit() {
+	c, ok := m.revertList.SelectedItem().(commitEntry)
+	if ok {
+		m.chosenRevert = true
+		m.revertCommit = c.sha
+		m.body = fmt.Sprintf("This reverts commit %s.", c.sha)
+		m.msgInput.SetValue(c.subject)
+		m.previousInputTexts = fmt.Sprintf(
+			"%s%s %s\n",
+			m.previousInputTexts,
+			revertListText,
+			selectedItemStyle.Render(fmt.Sprintf("%s %s", c.shortSHA(), c.subject)),
+		)
+	}
+}
+
+func (m *model) updateRevertList(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			m.quitting = true
+			return m, tea.Quit
+
+		case "enter":
+			// Let the list apply the filter first when one is being typed
+			if m.revertList.FilterState() != list.Filtering {
+				m.continueWithSelectedCommit()
+				return m, nil
+			}
+		}
+	}
+
+	var cmd tea.Cmd
+	m.revertList, cmd = m.revertList.Update(msg)
+	return m, cmd
+}
+
 func (m *model) updateScopeInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.Type {
 		case tea.KeyEnter:
+			if hasErrors(m.liveDiagnostics()) {
+				return m, nil
+			}
 			m.chosenScope = true
 			m.scope = m.scopeInput.Value()
-			m.typed += len(m.scope)
+			m.typed = m.headerLength(m.scope, "")
 			m.previousInputTexts = fmt.Sprintf(
 				"%s%s %s\n",
 				m.previousInputTexts,
@@ -287,13 +645,15 @@ func (m *model) updateScopeInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 			)
 			m.msgInput.Focus()
 		case tea.KeyTab:
-			m.scopeInput.SetValue(m.stagedFilePathSegments[m.scopeInputIndex])
-			if m.scopeInputIndex+1 == len(m.stagedFilePathSegments) {
-				m.scopeInputIndex = 0
-				return m, nil
+			if len(m.stagedFilePathSegments) > 0 {
+				m.scopeInput.SetValue(m.stagedFilePathSegments[m.scopeInputIndex])
+				if m.scopeInputIndex+1 == len(m.stagedFilePathSegments) {
+					m.scopeInputIndex = 0
+					return m, nil
+				}
+				m.scopeInputIndex += 1
+				m.scopeInput.CursorEnd()
 			}
-			m.scopeInputIndex += 1
-			m.scopeInput.CursorEnd()
 		case tea.KeyCtrlC, tea.KeyEsc:
 			return m, tea.Quit
 		}
@@ -309,9 +669,12 @@ func (m *model) updateMsgInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.Type {
 		case tea.KeyEnter:
+			if hasErrors(m.liveDiagnostics()) {
+				return m, nil
+			}
 			m.chosenMsg = true
 			m.msg = m.msgInput.Value()
-			m.typed += len(m.msg)
+			m.typed = m.headerLength(m.scope, m.msg)
 			m.previousInputTexts = fmt.Sprintf(
 				"%s%s %s\n",
 				m.previousInputTexts,
@@ -319,6 +682,12 @@ func (m *model) updateMsgInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 				selectedItemStyle.Render(m.msg),
 			)
 			m.ynInput.Focus()
+		case tea.KeyCtrlR:
+			if fixed, ok := m.imperativeFix(); ok {
+				m.msgInput.SetValue(fixed)
+				m.msgInput.CursorEnd()
+			}
+			return m, nil
 		case tea.KeyTab:
 			if len(m.commitMessages) > 0 {
 				m.msgInput.SetValue(m.commitMessages[m.messageInputIndex])
@@ -344,6 +713,11 @@ func (m *model) updateYNInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.Type {
 		case tea.KeyEnter:
+			// Without a body the message is final, so it has to pass the
+			// rules concerning the body as it is
+			if hasErrors(m.liveDiagnostics()) {
+				return m, nil
+			}
 			m.chosenMsg = true
 			switch strings.ToLower(m.ynInput.Value()) {
 			case "y":
@@ -367,11 +741,104 @@ func (m *model) updateYNInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// liveDiagnostics evaluates the rules concerning the input currently being
+// typed.
+func (m *model) liveDiagnostics() []diagnostic {
+	switch {
+	case !m.chosenScope:
+		scope := m.scopeInput.Value()
+		diagnostics := m.rules.checkScope(scope)
+		if d, ok := scopeEnum(m.scopes, scope); ok {
+			diagnostics = append(diagnostics, d.span(scope, 0, len(scope)))
+		}
+		return diagnostics
+	case !m.chosenMsg:
+		c := commitMessage{
+			Prefix:   m.prefix,
+			Scope:    m.scope,
+			Breaking: m.breaking,
+			Subject:  m.msgInput.Value(),
+		}
+		return m.rules.checkSubject(c.Header(), c.Subject)
+	case !m.chosenBody:
+		if strings.ToLower(m.ynInput.Value()) == "y" {
+			return nil
+		}
+		text, _ := m.CommitMessage()
+		return m.rules.checkBody(text, parseCommitMessage(text))
+	}
+	return nil
+}
+
+// imperativeFix returns the message being typed with its first word in the
+// imperative mood, if checking for it is enabled and the word is not.
+func (m *model) imperativeFix() (string, bool) {
+	if !enabled(m.rules.ImperativeMood.Severity) {
+		return "", false
+	}
+	_, fixed, ok := imperativeSubject(m.msgInput.Value(), m.rules.ImperativeMood.Overrides)
+	return fixed, ok
+}
+
+func renderDiagnostics(diagnostics []diagnostic) string {
+	var output string
+	for _, d := range diagnostics {
+		if d.Severity == severityError {
+			output += "\n" + errorStyle.Render("✗ "+d.Message)
+		} else {
+			output += "\n" + warningStyle.Render("! "+d.Message)
+		}
+	}
+	return output
+}
+
+// renderMisspellings repeats the message being typed with the misspelled
+// words highlighted.
+func renderMisspellings(m *model) string {
+	if !enabled(m.rules.Spelling.Severity) {
+		return ""
+	}
+	text := m.msgInput.Value()
+	found := m.rules.Spelling.misspellings(text)
+	if len(found) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, f := range found {
+		b.WriteString(text[last:f.start])
+		b.WriteString(errorStyle.Underline(true).Render(text[f.start:f.end]))
+		last = f.end
+	}
+	b.WriteString(text[last:])
+	return "\n  " + b.String()
+}
+
+func renderFixHint(m *model) string {
+	if _, ok := m.imperativeFix(); !ok {
+		return ""
+	}
+	fix := customKeys.Fix.Help()
+	return "\n" + itemDescriptionStyle.PaddingLeft(0).Render(fmt.Sprintf("%s to %s", fix.Key, fix.Desc))
+}
+
+// headerLength returns the length of the first line with the given scope
+// and message, counted the same way as by lintLimits.
+func (m *model) headerLength(scope string, subject string) int {
+	c := commitMessage{Prefix: m.prefix, Scope: scope, Breaking: m.breaking, Subject: subject}
+	return len(c.Header())
+}
+
 func renderCurrentLimit(m *model, charLimit int, input string) string {
 	var limit, inputLength int
 	if m.constrainInput {
 		limit = m.totalInputCharLimit
-		inputLength = len(m.prefix) + len("(): ") + len(input) + len(m.scope)
+		if m.chosenScope {
+			inputLength = m.headerLength(m.scope, input)
+		} else {
+			inputLength = m.headerLength(input, "")
+		}
 	} else {
 		limit = charLimit
 		inputLength = len(input)
@@ -393,8 +860,18 @@ func (m *model) View() string {
 	m.prefixList.NewStatusMessage(versionStyle(pkgVersion()))
 
 	switch {
+	case m.pickingFiles && !m.chosenFiles && m.patch != nil:
+		return "\n" + titleStyle.Render(m.patch.view())
+	case m.pickingFiles && !m.chosenFiles:
+		view := "\n" + m.fileList.View()
+		if m.fileError != "" {
+			view += titleStyle.Render(errorStyle.Render("✗ "+m.fileError)) + "\n"
+		}
+		return view
 	case !m.chosenPrefix:
 		return "\n" + m.prefixList.View()
+	case m.prefix == revertPrefix && !m.chosenRevert:
+		return titleStyle.Render(m.previousInputTexts) + "\n" + m.revertList.View()
 	case !m.chosenScope:
 		limit := renderCurrentLimit(m, m.scopeInput.CharLimit, m.scopeInput.Value())
 
@@ -408,11 +885,12 @@ func (m *model) View() string {
 		}
 
 		return titleStyle.Render(fmt.Sprintf(
-			"%s%s (Enter to skip / Esc to cancel) %s\n%s",
+			"%s%s (Enter to skip / Esc to cancel) %s\n%s%s",
 			m.previousInputTexts,
 			scopeInputText,
 			limit,
 			m.scopeInput.View(),
+			renderDiagnostics(m.liveDiagnostics()),
 		))
 	case !m.chosenMsg:
 		limit := renderCurrentLimit(m, m.msgInput.CharLimit, m.msgInput.Value())
@@ -427,18 +905,20 @@ func (m *model) View() string {
 		}
 
 		return titleStyle.Render(fmt.Sprintf(
-			"%s%s (Esc to cancel) %s\n%s",
+			"%s%s (Esc to cancel) %s\n%s%s",
 			m.previousInputTexts,
 			msgInputText,
 			limit,
 			m.msgInput.View(),
+			renderMisspellings(m)+renderDiagnostics(m.liveDiagnostics())+renderFixHint(m),
 		))
 	case !m.chosenBody:
 		return titleStyle.Render(fmt.Sprintf(
-			"%s%s (Esc to cancel)\n%s",
+			"%s%s (Esc to cancel)\n%s%s",
 			m.previousInputTexts,
 			bodyInputText,
 			m.ynInput.View(),
+			renderDiagnostics(m.liveDiagnostics()),
 		))
 	case m.quitting:
 		return quitTextStyle.Render("Aborted.\n")
@@ -527,6 +1007,60 @@ func findCommitMessages(grep string, findAll bool) tea.Cmd {
 	}
 }
 
+// stagePickedFiles adds the files to the staging area and then lists what is
+// staged, for the steps that follow.
+func stagePickedFiles(paths []string) tea.Cmd {
+	return func() tea.Msg {
+		root, err := repoRoot()
+		if err != nil {
+			return filesStagedMsg{err: err}
+		}
+		// The paths are relative to the root rather than to where we are
+		absolute := make([]string, len(paths))
+		for i, p := range paths {
+			absolute[i] = filepath.Join(root, p)
+		}
+		if len(absolute) > 0 {
+			if err := stageFiles(absolute...); err != nil {
+				return filesStagedMsg{err: err}
+			}
+		}
+		// Hunks may have been staged even if no file was picked
+		files, err := filesInStaging()
+		if err != nil && !errors.Is(err, errNothingStaged) {
+			return filesStagedMsg{err: err}
+		}
+		return filesStagedMsg{files: files}
+	}
+}
+
+func findRecentCommits(limit int) tea.Cmd {
+	return func() tea.Msg {
+		commits, err := recentCommits(limit)
+		if err != nil {
+			return recentCommitsMsg([]commitEntry{})
+		}
+		return recentCommitsMsg(commits)
+	}
+}
+
+// findKnownWords loads the dictionary ahead of time and gathers the words
+// from identifiers and paths in the staged changes, if spelling is checked.
+func findKnownWords(r spellingRule, stagedFiles []string) tea.Cmd {
+	return func() tea.Msg {
+		if !enabled(r.Severity) {
+			return nil
+		}
+		r.misspellings("")
+
+		words := stagedIdentifiers()
+		for w := range identifierWords(strings.Join(stagedFiles, " ")) {
+			words[w] = true
+		}
+		return knownWordsMsg(words)
+	}
+}
+
 func pkgVersion() string {
 	version := "unknown"
 	if info, ok := debug.ReadBuildInfo(); ok {
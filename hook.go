@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Characters git picks from when core.commentChar is set to "auto"
+const autoCommentChars = "#;@!$%^&|:"
+
+var (
+	scissorsPattern = regexp.MustCompile(`^. -+ >8 -+$`)
+	errNoTerminal   = errors.New("no terminal available")
+)
+
+// commentChar returns the character git uses to start comments in message
+// files, guessing it from the message when git chooses one automatically.
+func commentChar(text string) string {
+	char := gitConfig("core.commentChar", "#")
+	if char != "auto" {
+		return char
+	}
+
+	// The comments git adds always come after the message itself
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i] == "" {
+			continue
+		}
+		if strings.ContainsAny(lines[i][:1], autoCommentChars) {
+			return lines[i][:1]
+		}
+		break
+	}
+	return "#"
+}
+
+// cleanupMessage mimics the way git cleans up a message file before
+// committing it according to commit.cleanup.
+func cleanupMessage(text string, mode string, char string) string {
+	if mode == "verbatim" {
+		return text
+	}
+
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if mode != "whitespace" && strings.HasPrefix(line, char) && scissorsPattern.MatchString(line) {
+			break
+		}
+		if (mode == "strip" || mode == "default") && strings.HasPrefix(line, char) {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t"))
+	}
+
+	// Collapse consecutive blank lines and trim the leading and trailing ones
+	var cleaned []string
+	for _, line := range lines {
+		if line == "" && (len(cleaned) == 0 || cleaned[len(cleaned)-1] == "") {
+			continue
+		}
+		cleaned = append(cleaned, line)
+	}
+	return strings.TrimSpace(strings.Join(cleaned, "\n"))
+}
+
+func readMessageFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	text := string(data)
+	return cleanupMessage(text, gitConfig("commit.cleanup", "default"), commentChar(text)), nil
+}
+
+// runCommitMsg validates the message file passed in by git when installed as
+// a commit-msg hook.
+func runCommitMsg(c *config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s commit-msg <message file>", applicationName)
+	}
+
+	msg, err := readMessageFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	// The staged changes are what is being committed
+	if enabled(c.Rules.Spelling.Severity) {
+		c.Rules.Spelling.known = stagedIdentifiers()
+	}
+
+	errorCount := 0
+	for _, d := range lintMessage(c, msg) {
+		if d.Severity == severityError {
+			errorCount++
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "%s\n", d)
+	}
+
+	if errorCount > 0 {
+		return fmt.Errorf("commit message does not follow the conventions, found %d error(s)", errorCount)
+	}
+	return nil
+}
+
+// splitMessageFile separates the message from the comments git appends to
+// the message file, such as the status or a verbose diff. Only the block of
+// comments at the end counts, as comments within the message, e.g. those
+// between the messages of squashed commits, are part of what git wrote.
+func splitMessageFile(text string, char string) (string, string) {
+	lines := strings.Split(text, "\n")
+	end := len(lines)
+	// Everything from the scissors line on is cut off, diff included
+	for i, line := range lines {
+		if strings.HasPrefix(line, char) && scissorsPattern.MatchString(line) {
+			end = i
+			break
+		}
+	}
+
+	start := end
+	for start > 0 && (strings.TrimSpace(lines[start-1]) == "" || strings.HasPrefix(lines[start-1], char)) {
+		start--
+	}
+	// Blank lines before the comments stay with the message
+	for start < end && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	if start == len(lines) {
+		return text, ""
+	}
+	return strings.Join(lines[:start], "\n"), strings.Join(lines[start:], "\n")
+}
+
+// composeMessageFile runs the prompts on the terminal, pre-filled from the
+// message already in the file, and writes the result back while keeping the
+// comments git added. It returns whether a body was asked for.
+func composeMessageFile(c *config, path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	text := string(data)
+	msg, comments := splitMessageFile(text, commentChar(text))
+	msg = cleanupMessage(msg, "strip", commentChar(text))
+
+	// Git does not hand hooks or editors the terminal on standard input
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false, errNoTerminal
+	}
+	defer tty.Close()
+
+	stagedFiles, _ := filesInStaging()
+	m := newModel(c, stagedFiles, "")
+	if msg != "" {
+		m.Prefill(parseCommitMessage(msg))
+	}
+	if _, err := tea.NewProgram(m, tea.WithInput(tty), tea.WithOutput(tty)).Run(); err != nil {
+		return false, err
+	}
+
+	_, _ = fmt.Fprintln(tty, "")
+	if !m.Finished() {
+		return false, fmt.Errorf("terminated")
+	}
+
+	composed, withBody := m.CommitMessage()
+	composed += "\n"
+	if comments != "" {
+		composed += "\n" + comments
+	}
+	return withBody, os.WriteFile(path, []byte(composed), 0644)
+}
+
+// commitMessageFiles are the files git hands to the editor for a commit
+// message, as opposed to e.g. a rebase todo list or a tag message.
+var commitMessageFiles = []string{"COMMIT_EDITMSG", "MERGE_MSG", "SQUASH_MSG"}
+
+// regularEditor returns the editor the user would get without cometary.
+func regularEditor() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if e := os.Getenv(env); e != "" {
+			return e
+		}
+	}
+	return "vi"
+}
+
+// runEdit is meant to be set as GIT_EDITOR or core.editor. When a body is
+// asked for the file is handed over to the regular editor afterwards, and
+// files other than commit messages go straight to it.
+func runEdit(c *config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s edit <message file>", applicationName)
+	}
+	if !contains(commitMessageFiles, filepath.Base(args[0])) {
+		return runEditor(regularEditor(), args[0])
+	}
+
+	withBody, err := composeMessageFile(c, args[0])
+	if err != nil {
+		return err
+	}
+	if !withBody {
+		return nil
+	}
+	return runEditor(regularEditor(), args[0])
+}
+
+// runPrepareCommitMsg is meant to be installed as a prepare-commit-msg hook.
+// Messages given on the command line, reused from a commit or made by a merge
+// are left alone, as is everything when there is no terminal to run the
+// prompts on.
+func runPrepareCommitMsg(c *config, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: %s prepare-commit-msg <message file> [<source> [<sha>]]", applicationName)
+	}
+	if len(args) > 1 && contains([]string{"message", "commit", "merge"}, args[1]) {
+		return nil
+	}
+
+	_, err := composeMessageFile(c, args[0])
+	if errors.Is(err, errNoTerminal) {
+		return nil
+	}
+	return err
+}
+
+// runPrePush lints every commit about to be pushed when installed as a
+// pre-push hook, reading the refs being pushed from standard input.
+func runPrePush(c *config, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: %s pre-push <remote> [<url>]", applicationName)
+	}
+	remote := args[0]
+	zero := strings.Repeat("0", 40)
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	errorCount := 0
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[1] == zero {
+			continue
+		}
+		localSHA, remoteSHA := fields[1], fields[3]
+
+		revs := []string{remoteSHA + ".." + localSHA}
+		if remoteSHA == zero {
+			// A new branch, so only lint what no branch of the remote has
+			revs = []string{localSHA, "--not", "--remotes=" + remote}
+		}
+		commits, err := commitsInRange(revs...)
+		if err != nil {
+			return err
+		}
+		for _, commit := range commits {
+			for _, d := range lintMessage(c, commit.message) {
+				if d.Severity == severityError {
+					errorCount++
+				}
+				_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", commit.sha[:7], d)
+			}
+		}
+	}
+
+	if errorCount > 0 {
+		return fmt.Errorf("commits do not follow the conventions, found %d error(s)", errorCount)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestCleanupMessage(t *testing.T) {
+	const scissors = "# ------------------------ >8 ------------------------"
+	tests := []struct {
+		name string
+		text string
+		mode string
+		char string
+		want string
+	}{
+		{"comments", "feat: add x\n# Please enter the commit message\n\nbody\n", "strip", "#", "feat: add x\n\nbody"},
+		{"default is strip", "feat: add x\n# comment\n", "default", "#", "feat: add x"},
+		{"whitespace keeps comments", "feat: add x  \n# comment\n\n", "whitespace", "#", "feat: add x\n# comment"},
+		{"verbatim", "feat: add x  \n# comment\n\n", "verbatim", "#", "feat: add x  \n# comment\n\n"},
+		{"scissors", "feat: add x\n" + scissors + "\ndiff --git a/f b/f\n+x\n", "strip", "#", "feat: add x"},
+		{"scissors in whitespace mode", "feat: add x\n" + scissors + "\n", "whitespace", "#", "feat: add x\n" + scissors},
+		{"custom comment character", "feat: add x\n; comment\n# not a comment\n", "strip", ";", "feat: add x\n# not a comment"},
+		{"carriage returns", "feat: add x\r\n\r\nbody\r\n", "strip", "#", "feat: add x\n\nbody"},
+		{"blank lines", "\n\nfeat: add x\n\n\n\nbody \t\n\n", "strip", "#", "feat: add x\n\nbody"},
+	}
+	for _, tt := range tests {
+		if got := cleanupMessage(tt.text, tt.mode, tt.char); got != tt.want {
+			t.Errorf("%s: cleanupMessage() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// imperativeVerbs are verbs commonly found at the start of commit messages.
+// Each entry is the imperative form optionally followed by the past tense and
+// the present participle, separated by colons, whenever these cannot be
+// derived by the regular rules.
+var imperativeVerbs = []string{
+	"accept", "add", "adjust", "align", "allow", "apply", "avoid", "bump",
+	"build:built", "calculate", "change", "check", "clarify", "clean", "clear",
+	"close", "collapse", "combine", "compile", "configure", "convert", "copy",
+	"correct", "create", "cut:cut:cutting", "debug:debugged:debugging", "declare",
+	"decrease", "define", "delete", "deprecate", "detect", "disable", "display",
+	"downgrade", "drop:dropped:dropping", "emit:emitted:emitting", "enable",
+	"encode", "ensure", "expand", "expose", "extend", "extract", "fetch", "fill",
+	"filter", "finish", "fix", "format", "generate", "get:got:getting", "handle",
+	"hide:hid", "ignore", "implement", "import", "improve", "include", "increase",
+	"initialize", "inline", "insert", "install", "integrate", "introduce",
+	"invert", "keep:kept", "limit", "link", "load", "make:made", "mark", "merge",
+	"migrate", "modify", "move", "normalize", "omit:omitted:omitting", "optimize",
+	"parse", "pass", "pin:pinned:pinning", "polish", "prefer:preferred:preferring",
+	"prepare", "prevent", "print", "provide", "prune", "publish", "pull", "push",
+	"put:put:putting", "raise", "read:read", "rebuild:rebuilt", "redo:redid",
+	"reduce", "refactor", "reformat", "refresh", "register", "reject", "release",
+	"remove", "rename", "reorder", "reorganize", "replace", "report", "require",
+	"reset:reset:resetting", "resolve", "restore", "restrict", "restructure",
+	"retry", "return", "reuse", "revert", "review", "rewrite:rewrote", "rework",
+	"run:ran:running", "save", "send:sent", "separate", "set:set:setting", "show",
+	"simplify", "skip:skipped:skipping", "sort", "split:split:splitting", "start",
+	"stop:stopped:stopping", "store", "strip:stripped:stripping", "support",
+	"switch", "sync", "tidy", "toggle", "track", "trim:trimmed:trimming", "tweak",
+	"undo:undid", "unify", "update", "upgrade", "use", "validate", "verify",
+	"wrap:wrapped:wrapping", "write:wrote",
+}
+
+// nonImperativeForms maps the past tense, third person and present
+// participle of every verb back to its imperative form.
+var nonImperativeForms = buildNonImperativeForms()
+
+func buildNonImperativeForms() map[string]string {
+	forms := make(map[string]string)
+	for _, entry := range imperativeVerbs {
+		s := strings.Split(entry, ":")
+		verb := s[0]
+
+		past := pastTense(verb)
+		if len(s) > 1 {
+			past = s[1]
+		}
+		participle := presentParticiple(verb)
+		if len(s) > 2 {
+			participle = s[2]
+		}
+
+		for _, form := range []string{past, thirdPerson(verb), participle} {
+			// Some verbs, e.g. "set", have the same form in the past tense
+			if form != verb {
+				forms[form] = verb
+			}
+		}
+	}
+	return forms
+}
+
+func endsWithConsonantY(verb string) bool {
+	return len(verb) > 1 && strings.HasSuffix(verb, "y") && !strings.ContainsAny(verb[len(verb)-2:len(verb)-1], "aeiou")
+}
+
+func pastTense(verb string) string {
+	switch {
+	case strings.HasSuffix(verb, "e"):
+		return verb + "d"
+	case endsWithConsonantY(verb):
+		return verb[:len(verb)-1] + "ied"
+	default:
+		return verb + "ed"
+	}
+}
+
+func thirdPerson(verb string) string {
+	switch {
+	case strings.HasSuffix(verb, "o"):
+		return verb + "es"
+	case endsWithConsonantY(verb):
+		return verb[:len(verb)-1] + "ies"
+	}
+	for _, suffix := range []string{"s", "x", "z", "ch", "sh"} {
+		if strings.HasSuffix(verb, suffix) {
+			return verb + "es"
+		}
+	}
+	return verb + "s"
+}
+
+func presentParticiple(verb string) string {
+	if strings.HasSuffix(verb, "e") && !strings.HasSuffix(verb, "ee") {
+		return verb[:len(verb)-1] + "ing"
+	}
+	return verb + "ing"
+}
+
+// imperativeForm returns the imperative form of the word if it is a
+// non-imperative form of a known verb. Overrides take precedence, with an
+// empty value meaning the word is to be left alone.
+func imperativeForm(word string, overrides map[string]string) (string, bool) {
+	lower := strings.ToLower(word)
+	verb, ok := overrides[lower]
+	if !ok {
+		verb, ok = nonImperativeForms[lower]
+	}
+	if !ok || verb == "" {
+		return "", false
+	}
+
+	// Keep the capitalization of the original word
+	if r := []rune(word); unicode.IsUpper(r[0]) {
+		v := []rune(verb)
+		v[0] = unicode.ToUpper(v[0])
+		verb = string(v)
+	}
+	return verb, true
+}
+
+// imperativeSubject returns the first word of the subject along with the
+// subject where that word is replaced by its imperative form, if it has one.
+func imperativeSubject(subject string, overrides map[string]string) (string, string, bool) {
+	fields := strings.Fields(subject)
+	if len(fields) == 0 {
+		return "", "", false
+	}
+	verb, ok := imperativeForm(fields[0], overrides)
+	if !ok {
+		return "", "", false
+	}
+	trimmed := strings.TrimLeft(subject, " ")
+	return fields[0], verb + trimmed[len(fields[0]):], true
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	hookMarker     = "# Installed by " + applicationName
+	chainedHookExt = ".pre-" + applicationName
+)
+
+var managedHooks = []string{"commit-msg", "prepare-commit-msg", "pre-push"}
+
+// hookManager is a tool that owns the hooks of a repository, meaning hooks
+// must be added through its configuration instead of being installed.
+type hookManager struct {
+	name    string
+	files   []string
+	snippet string
+}
+
+var hookManagers = []hookManager{
+	{
+		name:  "husky",
+		files: []string{".husky"},
+		snippet: `Add the following to .husky/commit-msg:
+
+  cometary commit-msg "$1"
+
+and optionally to .husky/prepare-commit-msg:
+
+  cometary prepare-commit-msg "$@"`,
+	},
+	{
+		name:  "lefthook",
+		files: []string{"lefthook.yml", ".lefthook.yml", "lefthook.yaml", ".lefthook.yaml"},
+		snippet: `Add the following to the lefthook configuration:
+
+  commit-msg:
+    commands:
+      cometary:
+        run: cometary commit-msg {1}
+  pre-push:
+    commands:
+      cometary:
+        use_stdin: true
+        run: cometary pre-push {1} {2}`,
+	},
+	{
+		name:  "pre-commit",
+		files: []string{".pre-commit-config.yaml"},
+		snippet: `Add the following to .pre-commit-config.yaml and run
+'pre-commit install --hook-type commit-msg':
+
+  - repo: local
+    hooks:
+      - id: cometary
+        name: cometary
+        entry: cometary commit-msg
+        language: system
+        stages: [commit-msg]`,
+	},
+}
+
+func runHook(_ *config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s hook install|uninstall|status", applicationName)
+	}
+
+	if err := findGitDir(); err != nil {
+		return err
+	}
+	dir, err := hooksDir()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "install":
+		if manager, ok := detectHookManager(); ok {
+			fmt.Printf("Hooks are managed by %s, so nothing was installed.\n%s\n", manager.name, manager.snippet)
+			return nil
+		}
+		return installHooks(dir)
+	case "uninstall":
+		return uninstallHooks(dir)
+	case "status":
+		return hookStatus(dir)
+	default:
+		return fmt.Errorf("unknown hook action: %s", args[0])
+	}
+}
+
+func detectHookManager() (hookManager, bool) {
+	root, err := repoRoot()
+	if err != nil {
+		return hookManager{}, false
+	}
+
+	for _, manager := range hookManagers {
+		for _, f := range manager.files {
+			if _, err := os.Stat(filepath.Join(root, f)); err == nil {
+				return manager, true
+			}
+		}
+	}
+	if strings.Contains(gitConfig("core.hooksPath", ""), ".husky") {
+		return hookManagers[0], true
+	}
+	return hookManager{}, false
+}
+
+func isOwnHook(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(data), hookMarker)
+}
+
+func installHooks(dir string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, hook := range managedHooks {
+		path := filepath.Join(dir, hook)
+		if _, err := os.Stat(path); err == nil && !isOwnHook(path) {
+			if _, err := os.Stat(path + chainedHookExt); err == nil {
+				return fmt.Errorf("unable to chain %s as %s already exists", path, path+chainedHookExt)
+			}
+			if err := os.Rename(path, path+chainedHookExt); err != nil {
+				return err
+			}
+			fmt.Printf("Moved existing %s hook to %s, it will still be run first\n", hook, path+chainedHookExt)
+		}
+
+		if err := os.WriteFile(path, []byte(hookScript(hook, exe)), 0755); err != nil {
+			return err
+		}
+		fmt.Printf("Installed %s hook to %s\n", hook, path)
+	}
+	return nil
+}
+
+// hookScript returns a script that runs any hook that was in place before
+// and then hands over to this program. Only pre-push receives anything on
+// standard input, so only there it is buffered for both to read.
+func hookScript(hook string, exe string) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n" + hookMarker + "\n")
+	if hook == "pre-push" {
+		b.WriteString("input=$(cat)\n")
+		fmt.Fprintf(&b, "if [ -x \"$0%s\" ]; then\n", chainedHookExt)
+		fmt.Fprintf(&b, "\tprintf '%%s\\n' \"$input\" | \"$0%s\" \"$@\" || exit $?\n", chainedHookExt)
+		b.WriteString("fi\n")
+		fmt.Fprintf(&b, "printf '%%s\\n' \"$input\" | exec %s %s \"$@\"\n", shellQuote(exe), hook)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "if [ -x \"$0%s\" ]; then\n", chainedHookExt)
+	fmt.Fprintf(&b, "\t\"$0%s\" \"$@\" || exit $?\n", chainedHookExt)
+	b.WriteString("fi\n")
+	fmt.Fprintf(&b, "exec %s %s \"$@\"\n", shellQuote(exe), hook)
+	return b.String()
+}
+
+func uninstallHooks(dir string) error {
+	for _, hook := range managedHooks {
+		path := filepath.Join(dir, hook)
+		if !isOwnHook(path) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+
+		if _, err := os.Stat(path + chainedHookExt); err == nil {
+			if err := os.Rename(path+chainedHookExt, path); err != nil {
+				return err
+			}
+			fmt.Printf("Removed %s hook and restored the previous one\n", hook)
+			continue
+		}
+		fmt.Printf("Removed %s hook\n", hook)
+	}
+	return nil
+}
+
+func hookStatus(dir string) error {
+	if manager, ok := detectHookManager(); ok {
+		fmt.Printf("Hooks are managed by %s\n", manager.name)
+	}
+
+	fmt.Printf("Hooks directory: %s\n", dir)
+	for _, hook := range managedHooks {
+		path := filepath.Join(dir, hook)
+		_, err := os.Stat(path)
+		var status string
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			status = "not installed"
+		case err != nil:
+			return err
+		case !isOwnHook(path):
+			status = "not installed, another hook is in place"
+		default:
+			status = "installed"
+			if _, err := os.Stat(path + chainedHookExt); err == nil {
+				status += ", chained to the previous hook"
+			}
+		}
+		fmt.Printf(" > %s: %s\n", hook, status)
+	}
+	return nil
+}
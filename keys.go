@@ -3,7 +3,12 @@ package main
 import "github.com/charmbracelet/bubbles/key"
 
 type customKeyMap struct {
-	Cycle key.Binding
+	Cycle     key.Binding
+	Fix       key.Binding
+	Toggle    key.Binding
+	ToggleAll key.Binding
+	Patch     key.Binding
+	Files     key.Binding
 }
 
 var customKeys = customKeyMap{
@@ -11,4 +16,111 @@ var customKeys = customKeyMap{
 		key.WithKeys("tab"),
 		key.WithHelp("tab", "cycle through commit messages or changed file paths"),
 	),
+	Fix: key.NewBinding(
+		key.WithKeys("ctrl+r"),
+		key.WithHelp("ctrl+r", "use the suggested imperative form"),
+	),
+	Toggle: key.NewBinding(
+		key.WithKeys(" "),
+		key.WithHelp("space", "toggle file"),
+	),
+	ToggleAll: key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "toggle all files"),
+	),
+	Patch: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "stage hunks"),
+	),
+	Files: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "change staged files"),
+	),
+}
+
+type patchKeyMap struct {
+	Up       key.Binding
+	Down     key.Binding
+	PrevHunk key.Binding
+	NextHunk key.Binding
+	Select   key.Binding
+	Stage    key.Binding
+	Unstage  key.Binding
+	Switch   key.Binding
+	Back     key.Binding
+}
+
+var patchKeys = patchKeyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "previous line"),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "next line"),
+	),
+	PrevHunk: key.NewBinding(
+		key.WithKeys("left", "h"),
+		key.WithHelp("←/h", "previous hunk"),
+	),
+	NextHunk: key.NewBinding(
+		key.WithKeys("right", "l"),
+		key.WithHelp("→/l", "next hunk"),
+	),
+	Select: key.NewBinding(
+		key.WithKeys(" "),
+		key.WithHelp("space", "select line"),
+	),
+	Stage: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "stage hunk or selected lines"),
+	),
+	Unstage: key.NewBinding(
+		key.WithKeys("u"),
+		key.WithHelp("u", "unstage hunk or selected lines"),
+	),
+	Switch: key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab", "switch between unstaged and staged"),
+	),
+	Back: key.NewBinding(
+		key.WithKeys("esc", "q"),
+		key.WithHelp("esc", "back to files"),
+	),
+}
+
+type logKeyMap struct {
+	Type   key.Binding
+	Scope  key.Binding
+	Author key.Binding
+	Dates  key.Binding
+	Clear  key.Binding
+	Show   key.Binding
+}
+
+var logKeys = logKeyMap{
+	Type: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "filter by type"),
+	),
+	Scope: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "filter by scope"),
+	),
+	Author: key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "filter by author"),
+	),
+	Dates: key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "filter by dates"),
+	),
+	Clear: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "clear filters"),
+	),
+	Show: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "show commit"),
+	),
 }
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// diagnostic is a single problem with a message. The line and columns are
+// counted from 1, with the end column being the last character of the
+// problem, and are zero when the problem is not about any specific part.
+type diagnostic struct {
+	Rule      string
+	Severity  string
+	Message   string
+	Line      int
+	Column    int
+	EndColumn int
+}
+
+func (d diagnostic) String() string {
+	return fmt.Sprintf("%s [%s] %s", d.Severity, d.Rule, d.Message)
+}
+
+// span places the diagnostic at the characters of the text between the start
+// and end byte offsets.
+func (d diagnostic) span(text string, start int, end int) diagnostic {
+	if end > len(text) {
+		end = len(text)
+	}
+	if start > end {
+		start = end
+	}
+	before := text[:start]
+	lineStart := strings.LastIndex(before, "\n") + 1
+	d.Line = strings.Count(before, "\n") + 1
+	d.Column = utf8.RuneCountInString(text[lineStart:start]) + 1
+	d.EndColumn = d.Column + utf8.RuneCountInString(text[start:end]) - 1
+	if d.EndColumn < d.Column {
+		d.EndColumn = d.Column
+	}
+	return d
+}
+
+// shiftColumns moves the diagnostics that were placed relative to a part of
+// the first line to where that part starts.
+func shiftColumns(diagnostics []diagnostic, columns int) []diagnostic {
+	for i := range diagnostics {
+		if diagnostics[i].Line == 1 {
+			diagnostics[i].Column += columns
+			diagnostics[i].EndColumn += columns
+		}
+	}
+	return diagnostics
+}
+
+// lintTarget is a single message to be linted along with where it came from,
+// which is either a file name or the abbreviated hash of a commit.
+type lintTarget struct {
+	source  string
+	commit  string
+	message string
+}
+
+// skipLinting reports whether the message was generated by git itself and
+// should therefore not be held to the conventions.
+func skipLinting(header string) bool {
+	for _, p := range []string{"Merge ", "Revert \"", "fixup! ", "squash! ", "amend! "} {
+		if strings.HasPrefix(header, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeEnum returns the problem with a scope that is not one of the allowed
+// ones, if any are configured.
+func scopeEnum(scopes []string, scope string) (diagnostic, bool) {
+	if scope == "" || len(scopes) == 0 || contains(scopes, scope) {
+		return diagnostic{}, false
+	}
+	return diagnostic{
+		Rule:     "scope-enum",
+		Severity: severityError,
+		Message:  fmt.Sprintf("scope %q must be one of: %s", scope, strings.Join(scopes, ", ")),
+	}, true
+}
+
+// lintMessage checks the message against the configured prefixes, scopes,
+// character limits and rules, the same ones that are applied when composing.
+func lintMessage(c *config, text string) []diagnostic {
+	var diagnostics []diagnostic
+	msg := parseCommitMessage(text)
+	header := msg.Header()
+
+	if header == "" {
+		return []diagnostic{{Rule: "header-empty", Severity: severityError, Message: "message must not be empty"}}
+	}
+	if skipLinting(header) {
+		return diagnostics
+	}
+	if msg.Prefix == "" {
+		d := diagnostic{
+			Rule:     "header-format",
+			Severity: severityError,
+			Message:  fmt.Sprintf("header %q must be in the form '<prefix>[(<scope>)]: <message>'", header),
+		}
+		return []diagnostic{d.span(header, 0, len(header))}
+	}
+	scopeStart := len(msg.Prefix) + 1
+	subjectStart := len(header) - len(msg.Subject)
+
+	prefixes := make([]string, len(c.Prefixes))
+	for i, p := range c.Prefixes {
+		prefixes[i] = p.Title()
+	}
+	if !contains(prefixes, msg.Prefix) {
+		d := diagnostic{
+			Rule:     "prefix-enum",
+			Severity: severityError,
+			Message:  fmt.Sprintf("prefix %q must be one of: %s", msg.Prefix, strings.Join(prefixes, ", ")),
+		}
+		diagnostics = append(diagnostics, d.span(header, 0, len(msg.Prefix)))
+	}
+
+	if d, ok := scopeEnum(c.Scopes, msg.Scope); ok {
+		diagnostics = append(diagnostics, d.span(header, scopeStart, scopeStart+len(msg.Scope)))
+	}
+
+	if strings.TrimSpace(msg.Subject) == "" {
+		d := diagnostic{
+			Rule:     "subject-empty",
+			Severity: severityError,
+			Message:  "message after the prefix must not be empty",
+		}
+		diagnostics = append(diagnostics, d.span(header, subjectStart, len(header)))
+	}
+
+	diagnostics = append(diagnostics, lintLimits(c, header, msg)...)
+	diagnostics = append(diagnostics, c.Rules.check(text, msg)...)
+	return diagnostics
+}
+
+func lintLimits(c *config, header string, msg commitMessage) []diagnostic {
+	var diagnostics []diagnostic
+	if c.TotalInputCharLimit > 0 {
+		if len(header) > c.TotalInputCharLimit {
+			d := diagnostic{
+				Rule:     "header-max-length",
+				Severity: severityError,
+				Message:  fmt.Sprintf("header is %d characters long, limit is %d", len(header), c.TotalInputCharLimit),
+			}
+			diagnostics = append(diagnostics, d.span(header, c.TotalInputCharLimit, len(header)))
+		}
+		return diagnostics
+	}
+
+	scopeLimit := c.ScopeInputCharLimit
+	if scopeLimit == 0 {
+		scopeLimit = 16
+	}
+	if len(msg.Scope) > scopeLimit {
+		d := diagnostic{
+			Rule:     "scope-max-length",
+			Severity: severityError,
+			Message:  fmt.Sprintf("scope is %d characters long, limit is %d", len(msg.Scope), scopeLimit),
+		}
+		scopeStart := len(msg.Prefix) + 1
+		diagnostics = append(diagnostics, d.span(header, scopeStart+scopeLimit, scopeStart+len(msg.Scope)))
+	}
+
+	commitLimit := c.CommitInputCharLimit
+	if commitLimit == 0 {
+		commitLimit = 100
+	}
+	if len(msg.Subject) > commitLimit {
+		d := diagnostic{
+			Rule:     "subject-max-length",
+			Severity: severityError,
+			Message:  fmt.Sprintf("message is %d characters long, limit is %d", len(msg.Subject), commitLimit),
+		}
+		subjectStart := len(header) - len(msg.Subject)
+		diagnostics = append(diagnostics, d.span(header, subjectStart+commitLimit, len(header)))
+	}
+
+	return diagnostics
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func runLint(c *config, args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	file := flags.String("file", "", "read the message from a file, '-' for standard input")
+	revisions := flags.String("range", "", "lint the messages of the commits in a revision range")
+	format := flags.String("format", formatText, "output format: text, json, junit, sarif or github")
+	_ = flags.Parse(args)
+
+	write, ok := reportWriters[*format]
+	if !ok {
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+
+	targets, err := lintTargets(*file, *revisions)
+	if err != nil {
+		return err
+	}
+
+	results := make([]lintResult, len(targets))
+	errorCount := 0
+	for i, t := range targets {
+		results[i] = lintResult{target: t, diagnostics: lintMessage(c, t.message)}
+		for _, d := range results[i].diagnostics {
+			if d.Severity == severityError {
+				errorCount++
+			}
+		}
+	}
+	if err := write(os.Stdout, results); err != nil {
+		return err
+	}
+
+	if errorCount > 0 {
+		return fmt.Errorf("found %d error(s) in %d message(s)", errorCount, len(targets))
+	}
+	return nil
+}
+
+func lintTargets(file string, revisions string) ([]lintTarget, error) {
+	if revisions != "" {
+		if err := findGitDir(); err != nil {
+			return nil, err
+		}
+		commits, err := commitsInRange(revisions)
+		if err != nil {
+			return nil, err
+		}
+		targets := make([]lintTarget, len(commits))
+		for i, commit := range commits {
+			targets[i] = lintTarget{source: commit.sha[:7], commit: commit.sha, message: commit.message}
+		}
+		return targets, nil
+	}
+
+	var data []byte
+	var err error
+	if file == "" || file == "-" {
+		file = "stdin"
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []lintTarget{{source: file, message: string(data)}}, nil
+}
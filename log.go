@@ -0,0 +1,492 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	logCommitsLimit = 1000
+	// otherType stands in for the type of commits that do not follow the
+	// conventions
+	otherType  = "other"
+	dateLayout = "2006-01-02"
+)
+
+var (
+	logListText     = "Commits"
+	logTypeStyle    = lipgloss.NewStyle().Bold(true)
+	logCountStyle   = lipgloss.NewStyle().Foreground(characterCountColors)
+	logFilterFields = []string{"type", "scope", "author", "dates"}
+)
+
+// logEntry is a commit as shown in the history, along with how well its
+// message follows the conventions.
+type logEntry struct {
+	sha         string
+	author      string
+	email       string
+	date        time.Time
+	text        string
+	message     commitMessage
+	diagnostics []diagnostic
+}
+
+func (e logEntry) FilterValue() string { return e.sha + " " + e.message.Header() }
+
+func (e logEntry) kind() string {
+	if e.message.Prefix == "" {
+		return otherType
+	}
+	return e.message.Prefix
+}
+
+// worstSeverity returns the severity of the most serious diagnostic, or an
+// empty string if there are none.
+func (e logEntry) worstSeverity() string {
+	severity := ""
+	for _, d := range e.diagnostics {
+		if d.Severity == severityError {
+			return severityError
+		}
+		severity = d.Severity
+	}
+	return severity
+}
+
+// logFilter narrows the history down. Empty fields match everything, the
+// author matches part of the name or email, and the dates are inclusive.
+type logFilter struct {
+	kind   string
+	scope  string
+	author string
+	since  time.Time
+	until  time.Time
+}
+
+// parseDateRange parses a range in the form since..until, where either side
+// may be left out, or a single date.
+func parseDateRange(text string) (time.Time, time.Time, error) {
+	var since, until time.Time
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return since, until, nil
+	}
+	from, to, isRange := strings.Cut(text, "..")
+	if !isRange {
+		to = from
+	}
+
+	var err error
+	if from = strings.TrimSpace(from); from != "" {
+		if since, err = time.ParseInLocation(dateLayout, from, time.Local); err != nil {
+			return since, until, fmt.Errorf("dates must be in the form %s..%s", dateLayout, dateLayout)
+		}
+	}
+	if to = strings.TrimSpace(to); to != "" {
+		if until, err = time.ParseInLocation(dateLayout, to, time.Local); err != nil {
+			return since, until, fmt.Errorf("dates must be in the form %s..%s", dateLayout, dateLayout)
+		}
+	}
+	return since, until, nil
+}
+
+func (f logFilter) matches(e logEntry) bool {
+	switch {
+	case f.kind != "" && e.kind() != f.kind:
+		return false
+	case f.scope != "" && e.message.Scope != f.scope:
+		return false
+	case f.author != "" &&
+		!strings.Contains(strings.ToLower(e.author+" <"+e.email+">"), strings.ToLower(f.author)):
+		return false
+	case !f.since.IsZero() && e.date.Before(f.since):
+		return false
+	// Until the end of the day
+	case !f.until.IsZero() && !e.date.Before(f.until.AddDate(0, 0, 1)):
+		return false
+	}
+	return true
+}
+
+type logDelegate struct{}
+
+func (d logDelegate) Height() int                             { return 1 }
+func (d logDelegate) Spacing() int                            { return 0 }
+func (d logDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+func (d logDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	e, ok := listItem.(logEntry)
+	if !ok {
+		return
+	}
+
+	marker := " "
+	switch e.worstSeverity() {
+	case severityError:
+		marker = errorStyle.Render("✗")
+	case severityWarning:
+		marker = warningStyle.Render("!")
+	}
+
+	var output string
+	if index == m.Index() {
+		output = selectedItemPadded.Render("> " + e.sha[:7])
+	} else {
+		output = itemStyle.Render(e.sha[:7])
+	}
+	header := e.message.Header()
+	if e.message.Prefix != "" {
+		header = logTypeStyle.Render(e.message.Prefix) + strings.TrimPrefix(header, e.message.Prefix)
+	}
+	output += " " + marker + " " + header
+	output += itemDescriptionStyle.Render(fmt.Sprintf("%s, %s", e.author, e.date.Format(dateLayout)))
+
+	_, _ = fmt.Fprint(w, output)
+}
+
+type commitStatMsg struct {
+	sha  string
+	stat string
+}
+
+type logModel struct {
+	entries  []logEntry
+	filter   logFilter
+	list     list.Model
+	inputs   []textinput.Model
+	editing  int
+	err      string
+	showing  bool
+	selected logEntry
+	stat     string
+	detail   viewport.Model
+	height   int
+	quitting bool
+}
+
+func newLogModel(entries []logEntry, filter logFilter) *logModel {
+	l := list.New(nil, logDelegate{}, defaultWidth, listHeight)
+	l.Title = logListText
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(false)
+	l.Styles.Title = titleTextStyle
+	l.Styles.PaginationStyle = paginationStyle
+	l.Styles.HelpStyle = helpStyle
+	// The letters that would otherwise page through the list edit filters
+	l.KeyMap.PrevPage = key.NewBinding(key.WithKeys("left", "h", "pgup"), key.WithHelp("←/h/pgup", "prev page"))
+	l.KeyMap.NextPage = key.NewBinding(key.WithKeys("right", "l", "pgdown"), key.WithHelp("→/l/pgdn", "next page"))
+	l.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{logKeys.Show, logKeys.Type, logKeys.Scope, logKeys.Author, logKeys.Dates}
+	}
+	l.AdditionalFullHelpKeys = func() []key.Binding {
+		return []key.Binding{logKeys.Show, logKeys.Type, logKeys.Scope, logKeys.Author, logKeys.Dates, logKeys.Clear}
+	}
+
+	inputs := make([]textinput.Model, len(logFilterFields))
+	for i, field := range logFilterFields {
+		inputs[i] = textinput.New()
+		inputs[i].Prompt = field + ": "
+	}
+	inputs[0].SetValue(filter.kind)
+	inputs[1].SetValue(filter.scope)
+	inputs[2].SetValue(filter.author)
+	inputs[3].Placeholder = dateLayout + ".." + dateLayout
+	if !filter.since.IsZero() || !filter.until.IsZero() {
+		var since, until string
+		if !filter.since.IsZero() {
+			since = filter.since.Format(dateLayout)
+		}
+		if !filter.until.IsZero() {
+			until = filter.until.Format(dateLayout)
+		}
+		inputs[3].SetValue(since + ".." + until)
+	}
+
+	m := &logModel{
+		entries: entries,
+		filter:  filter,
+		list:    l,
+		inputs:  inputs,
+		editing: -1,
+		detail:  viewport.New(defaultWidth, listHeight),
+	}
+	m.applyFilter()
+	return m
+}
+
+// applyFilter shows the entries that match the filter, keeping the selected
+// one selected if it still matches.
+func (m *logModel) applyFilter() {
+	selected, _ := m.list.SelectedItem().(logEntry)
+	var items []list.Item
+	index := 0
+	for _, e := range m.entries {
+		if !m.filter.matches(e) {
+			continue
+		}
+		if e.sha == selected.sha {
+			index = len(items)
+		}
+		items = append(items, e)
+	}
+	m.list.SetItems(items)
+	m.list.Select(index)
+	m.list.Title = fmt.Sprintf("%s (%d of %d)", logListText, len(items), len(m.entries))
+}
+
+// readFilter takes over the value of the input being edited.
+func (m *logModel) readFilter() error {
+	value := strings.TrimSpace(m.inputs[m.editing].Value())
+	switch logFilterFields[m.editing] {
+	case "type":
+		m.filter.kind = value
+	case "scope":
+		m.filter.scope = value
+	case "author":
+		m.filter.author = value
+	case "dates":
+		since, until, err := parseDateRange(value)
+		if err != nil {
+			return err
+		}
+		m.filter.since, m.filter.until = since, until
+	}
+	return nil
+}
+
+// typeCounts renders the number of shown commits of each type, most common
+// first.
+func (m *logModel) typeCounts() string {
+	counts := make(map[string]int)
+	var kinds []string
+	for _, item := range m.list.Items() {
+		kind := item.(logEntry).kind()
+		if counts[kind] == 0 {
+			kinds = append(kinds, kind)
+		}
+		counts[kind]++
+	}
+	sort.SliceStable(kinds, func(i, j int) bool {
+		if counts[kinds[i]] != counts[kinds[j]] {
+			return counts[kinds[i]] > counts[kinds[j]]
+		}
+		return kinds[i] < kinds[j]
+	})
+
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		parts[i] = fmt.Sprintf("%s %s", kind, logCountStyle.Render(fmt.Sprint(counts[kind])))
+	}
+	return strings.Join(parts, "  ")
+}
+
+func (m *logModel) showDetail(e logEntry) tea.Cmd {
+	m.showing = true
+	m.selected = e
+	m.stat = ""
+	m.detail.SetContent(m.detailText())
+	m.detail.GotoTop()
+	return findCommitStat(e.sha)
+}
+
+func (m *logModel) detailText() string {
+	e := m.selected
+	var b strings.Builder
+	fmt.Fprintf(&b, "commit %s\nAuthor: %s <%s>\nDate:   %s\n\n", e.sha, e.author, e.email, e.date.Format(time.RFC1123Z))
+	for _, line := range strings.Split(strings.TrimRight(e.text, "\n"), "\n") {
+		b.WriteString("    " + line + "\n")
+	}
+	if diagnostics := renderDiagnostics(e.diagnostics); diagnostics != "" {
+		b.WriteString(diagnostics + "\n")
+	}
+	if m.stat != "" {
+		b.WriteString("\n" + m.stat)
+	}
+	return b.String()
+}
+
+func (m *logModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *logModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			m.quitting = true
+			return m, tea.Quit
+		}
+	case tea.WindowSizeMsg:
+		m.list.SetWidth(msg.Width)
+		m.height = msg.Height
+		m.detail.Width, m.detail.Height = msg.Width-2, msg.Height-2
+		return m, nil
+	case commitStatMsg:
+		if msg.sha == m.selected.sha {
+			m.stat = msg.stat
+			m.detail.SetContent(m.detailText())
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	switch {
+	case m.showing:
+		if msg, ok := msg.(tea.KeyMsg); ok && (msg.String() == "esc" || msg.String() == "q") {
+			m.showing = false
+			return m, nil
+		}
+		m.detail, cmd = m.detail.Update(msg)
+	case m.editing >= 0:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "enter":
+				if err := m.readFilter(); err != nil {
+					m.err = err.Error()
+					return m, nil
+				}
+				m.inputs[m.editing].Blur()
+				m.editing, m.err = -1, ""
+				m.applyFilter()
+				return m, nil
+			case "esc":
+				m.inputs[m.editing].Blur()
+				m.editing, m.err = -1, ""
+				return m, nil
+			}
+		}
+		m.inputs[m.editing], cmd = m.inputs[m.editing].Update(msg)
+	default:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			focus := -1
+			switch {
+			case key.Matches(msg, logKeys.Show):
+				if e, ok := m.list.SelectedItem().(logEntry); ok {
+					return m, m.showDetail(e)
+				}
+				return m, nil
+			case key.Matches(msg, logKeys.Type):
+				focus = 0
+			case key.Matches(msg, logKeys.Scope):
+				focus = 1
+			case key.Matches(msg, logKeys.Author):
+				focus = 2
+			case key.Matches(msg, logKeys.Dates):
+				focus = 3
+			case key.Matches(msg, logKeys.Clear):
+				m.filter = logFilter{}
+				for i := range m.inputs {
+					m.inputs[i].SetValue("")
+				}
+				m.applyFilter()
+				return m, nil
+			}
+			if focus >= 0 {
+				m.editing = focus
+				m.inputs[focus].CursorEnd()
+				return m, m.inputs[focus].Focus()
+			}
+		}
+		m.list, cmd = m.list.Update(msg)
+	}
+	return m, cmd
+}
+
+func (m *logModel) View() string {
+	switch {
+	case m.quitting:
+		return ""
+	case m.showing:
+		return titleStyle.Render(m.detail.View())
+	}
+
+	var filters []string
+	for i, input := range m.inputs {
+		if i == m.editing {
+			filters = append(filters, input.View())
+		} else if value := input.Value(); value != "" {
+			filters = append(filters, input.Prompt+selectedItemStyle.Render(value))
+		}
+	}
+	status := m.typeCounts()
+	if len(filters) > 0 {
+		status = strings.Join(filters, "  ") + "\n" + status
+	}
+	if m.err != "" {
+		status += "\n" + errorStyle.Render("✗ "+m.err)
+	}
+	// The list takes up whatever room the filters and counts leave
+	if m.height > 0 {
+		m.list.SetHeight(m.height - lipgloss.Height(status) - 1)
+	}
+	return "\n" + titleStyle.Render(status) + "\n" + m.list.View()
+}
+
+func findCommitStat(sha string) tea.Cmd {
+	return func() tea.Msg {
+		// Any error is shown in place of the stat
+		output, _ := exec.Command("git", "show", "--stat", "--format=", sha).CombinedOutput()
+		return commitStatMsg{sha: sha, stat: strings.Trim(string(output), "\n")}
+	}
+}
+
+func runLog(c *config, args []string) error {
+	flags := flag.NewFlagSet("log", flag.ExitOnError)
+	kind := flags.String("type", "", "only show commits of this type, or \""+otherType+"\" for those that do not follow the conventions")
+	scope := flags.String("scope", "", "only show commits with this scope")
+	author := flags.String("author", "", "only show commits by authors whose name or email contains this")
+	since := flags.String("since", "", "only show commits made on or after this date ("+dateLayout+")")
+	until := flags.String("until", "", "only show commits made on or before this date ("+dateLayout+")")
+	limit := flags.Int("max-count", logCommitsLimit, "number of commits to load")
+	_ = flags.Parse(args)
+
+	if err := findGitDir(); err != nil {
+		return err
+	}
+
+	filter := logFilter{kind: *kind, scope: *scope, author: *author}
+	var err error
+	if filter.since, filter.until, err = parseDateRange(*since + ".." + *until); err != nil {
+		return err
+	}
+
+	revs := append([]string{fmt.Sprintf("--max-count=%d", *limit)}, flags.Args()...)
+	commits, err := commitsInRange(revs...)
+	if err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		return fmt.Errorf("no commits to show")
+	}
+
+	entries := make([]logEntry, len(commits))
+	for i, commit := range commits {
+		entries[i] = logEntry{
+			sha:         commit.sha,
+			author:      commit.author,
+			email:       commit.email,
+			date:        commit.date,
+			text:        commit.message,
+			message:     parseCommitMessage(commit.message),
+			diagnostics: lintMessage(c, commit.message),
+		}
+	}
+
+	m := newLogModel(entries, filter)
+	if _, err := tea.NewProgram(m, tea.WithAltScreen()).Run(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -8,14 +9,49 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+var subcommands = map[string]func(*config, []string) error{
+	"bump":               runBump,
+	"changelog":          runChangelog,
+	"commit-msg":         runCommitMsg,
+	"compliance":         runCompliance,
+	"edit":               runEdit,
+	"fixup":              runFixup,
+	"hook":               runHook,
+	"lint":               runLint,
+	"log":                runLog,
+	"pre-push":           runPrePush,
+	"prepare-commit-msg": runPrepareCommitMsg,
+	"release-notes":      runNotes,
+	"reword":             runReword,
+	"sequence-editor":    runSequenceEditor,
+}
+
+// ownConfigCommands only need the configuration file, so the configuration
+// of other tools is not looked for when running them.
+var ownConfigCommands = map[string]bool{
+	"-s":              true,
+	"fixup":           true,
+	"hook":            true,
+	"sequence-editor": true,
+}
+
 func main() {
-	config := loadConfig()
+	config := loadConfig(len(os.Args) < 2 || !ownConfigCommands[os.Args[1]])
 
 	format := config.ShowStatsFormat
 	if config.SessionStatAsSeconds {
 		format = "seconds"
 	}
 
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(config, os.Args[2:]); err != nil {
+				fail(err.Error())
+			}
+			os.Exit(0)
+		}
+	}
+
 	tracker, err := NewRuntimeTracker("")
 	if err != nil {
 		fail("error creating tracker: %s", err)
@@ -34,11 +70,47 @@ func main() {
 		fail(err.Error())
 	}
 
+	amend := false
+	for _, arg := range os.Args[1:] {
+		if arg == "--amend" {
+			amend = true
+		}
+	}
+
 	stagedFiles, err := filesInStaging()
-	if err != nil {
+	if err != nil && !errors.Is(err, errNothingStaged) {
 		fail(err.Error())
 	}
 
+	// An empty staging area is fine when only amending the message or when
+	// the working tree is to be prepared by reverting a commit chosen later
+	// on, and otherwise the changed files are offered for staging first
+	var changed []changedFile
+	if errors.Is(err, errNothingStaged) && !amend {
+		if changed, err = changedFiles(); err != nil {
+			fail(err.Error())
+		}
+		if len(changed) == 0 && !config.PrepareRevert {
+			fail(errNothingStaged.Error())
+		}
+	}
+
+	scopeFiles := stagedFiles
+	var amended commitMessage
+	if amend {
+		text, err := messageOfCommit("HEAD")
+		if err != nil {
+			fail("error reading HEAD: %s", err)
+		}
+		amended = parseCommitMessage(text)
+
+		committedFiles, err := filesInCommit("HEAD")
+		if err != nil {
+			fail("error reading HEAD: %s", err)
+		}
+		scopeFiles = append(committedFiles, stagedFiles...)
+	}
+
 	commitSearchTerm := ""
 	if len(os.Args) > 1 && os.Args[1] == "-m" {
 		commitSearchTerm = os.Args[2]
@@ -48,15 +120,41 @@ func main() {
 		tracker.Start()
 	}
 
-	m := newModel(config, stagedFiles, commitSearchTerm)
+	// Files can be staged and unstaged in the prompts, so the staging area
+	// is put back as it was when aborting
+	index, _ := indexTree()
+
+	m := newModel(config, scopeFiles, commitSearchTerm)
+	if amend {
+		m.Prefill(amended)
+	}
+	if len(changed) > 0 {
+		m.PickFiles(changed, config.PrepareRevert)
+	} else if !amend {
+		m.AllowFilePicking(config.PrepareRevert)
+	}
 	if _, err := tea.NewProgram(m).Run(); err != nil {
 		fail(err.Error())
 	}
 
 	fmt.Println("")
 	if !m.Finished() {
+		if current, err := indexTree(); index != "" && err == nil && current != index {
+			if err := restoreIndex(index); err != nil {
+				fail("error restoring the staging area: %s", err)
+			}
+		}
 		fail("terminated")
 	}
+	stagedFiles = m.StagedFiles()
+
+	if sha := m.RevertCommit(); sha != "" && config.PrepareRevert {
+		if err := revertWorkingTree(sha); err != nil {
+			fail("error reverting: %s", err)
+		}
+	} else if len(stagedFiles) == 0 && !amend {
+		fail(errNothingStaged.Error())
+	}
 
 	msg, withBody := m.CommitMessage()
 	if err := commit(msg, withBody, config.SignOffCommits); err != nil {
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	headerPattern = regexp.MustCompile(`^(\w[\w-]*)(?:\(([^()]*)\))?(!)?: (.*)$`)
+	footerPattern = regexp.MustCompile(`^([\w-]+|BREAKING[ -]CHANGE)(?:: | #)(.*)$`)
+)
+
+type footer struct {
+	Token string
+	Value string
+}
+
+func (f footer) String() string {
+	if strings.HasPrefix(f.Value, "#") {
+		return fmt.Sprintf("%s %s", f.Token, f.Value)
+	}
+	return fmt.Sprintf("%s: %s", f.Token, f.Value)
+}
+
+// commitMessage is a commit message split into the parts defined by the
+// Conventional Commits specification. Breaking is only set when the header is
+// marked with an exclamation mark, see IsBreaking for the complete check.
+type commitMessage struct {
+	Prefix   string
+	Scope    string
+	Breaking bool
+	Subject  string
+	Body     string
+	Footers  []footer
+}
+
+// parseCommitMessage splits a commit message into its parts. A header that
+// does not follow the conventions ends up in Subject as a whole.
+func parseCommitMessage(text string) commitMessage {
+	var c commitMessage
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+
+	header, rest, _ := strings.Cut(text, "\n")
+	if s := headerPattern.FindStringSubmatch(header); s != nil {
+		c.Prefix = s[1]
+		c.Scope = s[2]
+		c.Breaking = s[3] == "!"
+		c.Subject = s[4]
+	} else {
+		c.Subject = header
+	}
+
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return c
+	}
+
+	// Footers can only be found in the last paragraph
+	paragraphs := strings.Split(rest, "\n\n")
+	last := paragraphs[len(paragraphs)-1]
+	if footers, ok := parseFooters(last); ok {
+		c.Footers = footers
+		paragraphs = paragraphs[:len(paragraphs)-1]
+	}
+	c.Body = strings.TrimSpace(strings.Join(paragraphs, "\n\n"))
+	return c
+}
+
+func parseFooters(paragraph string) ([]footer, bool) {
+	var footers []footer
+	for _, line := range strings.Split(paragraph, "\n") {
+		if s := footerPattern.FindStringSubmatch(line); s != nil {
+			value := s[2]
+			if strings.HasPrefix(line[len(s[1]):], " #") {
+				value = "#" + value
+			}
+			footers = append(footers, footer{Token: s[1], Value: value})
+			continue
+		}
+		// Anything else can only be a continuation of the previous footer
+		if len(footers) == 0 {
+			return nil, false
+		}
+		footers[len(footers)-1].Value += "\n" + line
+	}
+	return footers, true
+}
+
+// Header returns the first line of the message.
+func (c commitMessage) Header() string {
+	if c.Prefix == "" {
+		return c.Subject
+	}
+	header := c.Prefix
+	if c.Scope != "" {
+		header = fmt.Sprintf("%s(%s)", header, c.Scope)
+	}
+	if c.Breaking {
+		header += "!"
+	}
+	return fmt.Sprintf("%s: %s", header, c.Subject)
+}
+
+// Trailer returns the body and footers as they would follow the header.
+func (c commitMessage) Trailer() string {
+	var parts []string
+	if c.Body != "" {
+		parts = append(parts, c.Body)
+	}
+	if len(c.Footers) > 0 {
+		lines := make([]string, len(c.Footers))
+		for i, f := range c.Footers {
+			lines[i] = f.String()
+		}
+		parts = append(parts, strings.Join(lines, "\n"))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func (c commitMessage) String() string {
+	if trailer := c.Trailer(); trailer != "" {
+		return fmt.Sprintf("%s\n\n%s", c.Header(), trailer)
+	}
+	return c.Header()
+}
+
+// IsBreaking reports whether the header or a footer marks a breaking change.
+func (c commitMessage) IsBreaking() bool {
+	if c.Breaking {
+		return true
+	}
+	for _, f := range c.Footers {
+		if f.Token == "BREAKING CHANGE" || f.Token == "BREAKING-CHANGE" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// defaultNotesTemplate is used when no template is given.
+const defaultNotesTemplate = `# {{ .Version }}
+{{- if .Breaking }}
+
+## ⚠ Breaking Changes
+{{ range .Breaking }}
+- {{ .BreakingChange }} ({{ .ShortSHA }})
+{{- end }}
+{{- end }}
+{{- range .Sections }}
+
+## {{ .Title }}
+{{ range .Entries }}
+- {{ if .Message.Scope }}**{{ .Message.Scope }}:** {{ end }}{{ .Message.Subject }} ({{ .ShortSHA }})
+{{- end }}
+{{- end }}
+{{- with .Contributors }}
+
+## Contributors
+{{ range . }}
+- {{ .Name }}
+{{- end }}
+{{- end }}
+`
+
+var coAuthorPattern = regexp.MustCompile(`^(.*?)\s*<([^>]*)>$`)
+
+type contributor struct {
+	Name  string
+	Email string
+}
+
+// Contributors returns the authors of the commits along with anyone credited
+// with a Co-authored-by trailer, sorted by name.
+func (r release) Contributors() []contributor {
+	var contributors []contributor
+	seen := make(map[string]bool)
+	add := func(name string, email string) {
+		key := strings.ToLower(email)
+		if key == "" {
+			key = name
+		}
+		if name == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		contributors = append(contributors, contributor{Name: name, Email: email})
+	}
+
+	for _, e := range r.Entries {
+		add(e.Author, e.Email)
+		for _, f := range e.Message.Footers {
+			if !strings.EqualFold(f.Token, "Co-authored-by") {
+				continue
+			}
+			if s := coAuthorPattern.FindStringSubmatch(f.Value); s != nil {
+				add(s[1], s[2])
+			} else {
+				add(f.Value, "")
+			}
+		}
+	}
+	sort.SliceStable(contributors, func(i, j int) bool {
+		return strings.ToLower(contributors[i].Name) < strings.ToLower(contributors[j].Name)
+	})
+	return contributors
+}
+
+// Issues returns every issue referenced by the commits, in numerical order.
+func (r release) Issues() []string {
+	var issues []string
+	for _, e := range r.Entries {
+		for _, id := range e.Issues {
+			if !contains(issues, id) {
+				issues = append(issues, id)
+			}
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		a, _ := strconv.Atoi(issues[i])
+		b, _ := strconv.Atoi(issues[j])
+		return a < b
+	})
+	return issues
+}
+
+// notesFuncs are the functions available to templates in addition to the
+// built-in ones.
+func notesFuncs(c *config) template.FuncMap {
+	commitURL, issueURL := linkTemplates(c)
+	return template.FuncMap{
+		"commitURL": func(sha string) string {
+			return strings.ReplaceAll(commitURL, "{sha}", sha)
+		},
+		"issueURL": func(id string) string {
+			return strings.ReplaceAll(issueURL, "{id}", id)
+		},
+		"join":  strings.Join,
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+	}
+}
+
+func runNotes(c *config, args []string) error {
+	flags := flag.NewFlagSet("release-notes", flag.ExitOnError)
+	templateFile := flags.String("template", "", "text/template file to render, defaults to a built-in one")
+	from := flags.String("from", "", "start of the range, defaults to the latest tag")
+	to := flags.String("to", "HEAD", "end of the range")
+	version := flags.String("version", "", "version of the release, defaults to the tag at the end of the range or \""+unreleasedVersion+"\"")
+	output := flags.String("output", "", "write the release notes to a file instead of standard output")
+	_ = flags.Parse(args)
+
+	text := defaultNotesTemplate
+	name := "default"
+	if *templateFile != "" {
+		data, err := os.ReadFile(*templateFile)
+		if err != nil {
+			return err
+		}
+		text, name = string(data), *templateFile
+	}
+	tmpl, err := template.New(name).Funcs(notesFuncs(c)).Parse(text)
+	if err != nil {
+		return err
+	}
+
+	if err := findGitDir(); err != nil {
+		return err
+	}
+	start, end, err := releaseRange(*from, *to, anyTag)
+	if err != nil {
+		return err
+	}
+	r, err := collectRelease(c, start, end)
+	if err != nil {
+		return err
+	}
+	r.Version = releaseVersion(*version, end)
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := tmpl.Execute(out, r); err != nil {
+		return fmt.Errorf("unable to render %s: %w", name, err)
+	}
+	return nil
+}
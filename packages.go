@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// name returns the path of the package as used in its tags.
+func (p releasePackage) name() string {
+	return strings.Trim(filepath.ToSlash(filepath.Clean(p.Path)), "/")
+}
+
+// scopes returns the scopes that attribute a commit to the package, which
+// default to the last element of its path, e.g. billing.
+func (p releasePackage) scopes() []string {
+	if len(p.Scopes) > 0 {
+		return p.Scopes
+	}
+	return []string{path.Base(p.name())}
+}
+
+// tagFormat returns the format of the tags of the package, following the
+// tags of nested Go modules, e.g. services/billing/v{version}.
+func (p releasePackage) tagFormat(c *config) string {
+	return p.name() + "/" + tagFormat(c)
+}
+
+func (p releasePackage) hasFile(file string) bool {
+	return file == p.name() || strings.HasPrefix(file, p.name()+"/")
+}
+
+// selectPackages returns the package with the given path, or all of them if
+// the path is empty.
+func selectPackages(c *config, name string) ([]releasePackage, error) {
+	if name == "" {
+		return c.Packages, nil
+	}
+	for _, p := range c.Packages {
+		if p.name() == strings.Trim(filepath.ToSlash(filepath.Clean(name)), "/") {
+			return []releasePackage{p}, nil
+		}
+	}
+	return nil, fmt.Errorf("no package with path %s under packages", name)
+}
+
+// packageAttribution decides which commits belong to a package. A commit
+// whose scope is that of a package belongs to that package only, while any
+// other commit belongs to every package it touches files in.
+type packageAttribution struct {
+	packages []releasePackage
+	files    map[string][]string
+}
+
+func newPackageAttribution(c *config) *packageAttribution {
+	return &packageAttribution{packages: c.Packages, files: make(map[string][]string)}
+}
+
+// filter returns a function that keeps the commits of the package, for use
+// with filterRelease.
+func (a *packageAttribution) filter(p releasePackage) func(changelogEntry) (bool, error) {
+	return func(e changelogEntry) (bool, error) {
+		if scope := e.Message.Scope; scope != "" {
+			for _, other := range a.packages {
+				if contains(other.scopes(), scope) {
+					return contains(p.scopes(), scope), nil
+				}
+			}
+		}
+
+		files, ok := a.files[e.SHA]
+		if !ok {
+			var err error
+			if files, err = filesInCommit(e.SHA); err != nil {
+				return false, err
+			}
+			a.files[e.SHA] = files
+		}
+		for _, f := range files {
+			if p.hasFile(f) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// versionTag finds the latest tag in the format for releaseRange, e.g. that
+// of a package.
+func versionTag(format string) func(rev string) (string, error) {
+	return func(rev string) (string, error) {
+		tag, _, _, err := latestVersion(format, rev, false)
+		return tag, err
+	}
+}
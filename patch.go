@@ -0,0 +1,381 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const patchHeight = 20
+
+var (
+	hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+	addedLineStyle    = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#5b8a3a", Dark: "#a3be8c"})
+	removedLineStyle  = errorStyle
+	contextLineStyle  = lipgloss.NewStyle().Faint(true)
+)
+
+// hunk is a part of a diff, where each line starts with ' ', '+', '-' or,
+// for "\ No newline at end of file", a backslash.
+type hunk struct {
+	header   string
+	oldStart int
+	newStart int
+	lines    []string
+}
+
+// fileDiff is the diff of a single file, split into hunks.
+type fileDiff struct {
+	header []string
+	hunks  []hunk
+}
+
+func parseDiff(text string) fileDiff {
+	var d fileDiff
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if s := hunkHeaderPattern.FindStringSubmatch(line); s != nil {
+			h := hunk{header: line}
+			h.oldStart, _ = strconv.Atoi(s[1])
+			h.newStart, _ = strconv.Atoi(s[3])
+			d.hunks = append(d.hunks, h)
+			continue
+		}
+		if len(d.hunks) == 0 {
+			if line != "" {
+				d.header = append(d.header, line)
+			}
+			continue
+		}
+		h := &d.hunks[len(d.hunks)-1]
+		h.lines = append(h.lines, line)
+	}
+	return d
+}
+
+func isChange(line string) bool {
+	return strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")
+}
+
+// patchLine is a line of a patch along with the "\\ No newline at end of
+// file" marker that followed it in the diff, if any.
+type patchLine struct {
+	text   string
+	marker string
+}
+
+// changedLine is an added or removed line of a hunk along with the marker
+// that may follow it, and whether it is part of the patch.
+type changedLine struct {
+	patchLine
+	keep bool
+}
+
+func (l changedLine) line(asContext bool) patchLine {
+	if asContext {
+		return patchLine{text: " " + l.text[1:], marker: l.marker}
+	}
+	return l.patchLine
+}
+
+// patch returns a patch of the hunk limited to the selected lines, or all of
+// them if none are. Lines left out are dropped when they would not be there
+// after applying it and kept as context otherwise, which for a reversed patch
+// is the other way around.
+func (d fileDiff) patch(h hunk, selected map[int]bool, reverse bool) string {
+	var out []patchLine
+	var removed, added []changedLine
+	var last *changedLine
+
+	// Within each run of changes, lines kept as context go where the result
+	// reads as if only the selected lines had been changed, e.g. staging just
+	// "2" becoming "two" out of "2 3" becoming "two three" gives "two 3"
+	flush := func() {
+		if !reverse {
+			lastKept := -1
+			for i, l := range removed {
+				if l.keep {
+					lastKept = i
+				}
+			}
+			for _, l := range removed[:lastKept+1] {
+				out = append(out, l.line(!l.keep))
+			}
+			for _, l := range added {
+				if l.keep {
+					out = append(out, l.line(false))
+				}
+			}
+			for _, l := range removed[lastKept+1:] {
+				out = append(out, l.line(true))
+			}
+		} else {
+			firstKept := len(added)
+			for i, l := range added {
+				if l.keep {
+					firstKept = i
+					break
+				}
+			}
+			for _, l := range added[:firstKept] {
+				out = append(out, l.line(true))
+			}
+			for _, l := range removed {
+				if l.keep {
+					out = append(out, l.line(false))
+				}
+			}
+			for _, l := range added[firstKept:] {
+				out = append(out, l.line(!l.keep))
+			}
+		}
+		removed, added, last = nil, nil, nil
+	}
+
+	for i, line := range h.lines {
+		keep := len(selected) == 0 || selected[i]
+		switch {
+		// The marker belongs to the line before it
+		case strings.HasPrefix(line, "\\"):
+			if last != nil {
+				last.marker = line
+			} else if len(out) > 0 {
+				out[len(out)-1].marker = line
+			}
+		case strings.HasPrefix(line, "-"):
+			removed = append(removed, changedLine{patchLine: patchLine{text: line}, keep: keep})
+			last = &removed[len(removed)-1]
+		case strings.HasPrefix(line, "+"):
+			added = append(added, changedLine{patchLine: patchLine{text: line}, keep: keep})
+			last = &added[len(added)-1]
+		default:
+			flush()
+			out = append(out, patchLine{text: line})
+		}
+	}
+	flush()
+
+	// A line without a newline can only end the old or the new side, so
+	// the marker is dropped from lines that no longer do, and a line of
+	// context that ends just one of them is split up
+	endOld, endNew := -1, -1
+	for i, l := range out {
+		switch {
+		case strings.HasPrefix(l.text, "-"):
+			endOld = i
+		case strings.HasPrefix(l.text, "+"):
+			endNew = i
+		default:
+			endOld, endNew = i, i
+		}
+	}
+	var lines []string
+	for i, l := range out {
+		context := !strings.HasPrefix(l.text, "-") && !strings.HasPrefix(l.text, "+")
+		switch {
+		case l.marker == "":
+			lines = append(lines, l.text)
+		case context && (i == endOld) != (i == endNew):
+			text := strings.TrimPrefix(l.text, " ")
+			lines = append(lines, "-"+text)
+			if i == endOld {
+				lines = append(lines, l.marker)
+			}
+			lines = append(lines, "+"+text)
+			if i == endNew {
+				lines = append(lines, l.marker)
+			}
+		case i == endOld || i == endNew:
+			lines = append(lines, l.text, l.marker)
+		default:
+			lines = append(lines, l.text)
+		}
+	}
+
+	// The counts are worked out by git apply --recount
+	header := fmt.Sprintf("@@ -%d +%d @@", h.oldStart, h.newStart)
+	return strings.Join(d.header, "\n") + "\n" + header + "\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// patchView shows the diff of a file hunk by hunk, for staging or unstaging
+// single hunks or lines of them.
+type patchView struct {
+	path     string
+	staged   bool
+	diff     fileDiff
+	hunk     int
+	line     int
+	selected map[int]bool
+	err      string
+}
+
+func newPatchView(f changedFile) (*patchView, error) {
+	v := &patchView{path: f.path, staged: f.status[1] == ' '}
+	return v, v.load()
+}
+
+// load reads the diff of the side shown, keeping the position as far as it
+// still exists.
+func (v *patchView) load() error {
+	text, err := diffOfFile(v.path, v.staged)
+	if err != nil {
+		return err
+	}
+	v.diff = parseDiff(text)
+	if v.hunk >= len(v.diff.hunks) {
+		v.hunk = len(v.diff.hunks) - 1
+	}
+	if v.hunk < 0 {
+		v.hunk = 0
+	}
+	v.selectHunk(v.hunk)
+	return nil
+}
+
+func (v *patchView) current() (hunk, bool) {
+	if v.hunk >= len(v.diff.hunks) {
+		return hunk{}, false
+	}
+	return v.diff.hunks[v.hunk], true
+}
+
+// selectHunk moves to the first change of the hunk.
+func (v *patchView) selectHunk(i int) {
+	v.hunk = i
+	v.selected = make(map[int]bool)
+	v.line = 0
+	v.moveLine(0)
+}
+
+// moveLine moves the cursor to the next change in the direction given, or to
+// the nearest one from where it is with a direction of 0.
+func (v *patchView) moveLine(direction int) {
+	h, ok := v.current()
+	if !ok {
+		return
+	}
+	step := direction
+	if step == 0 {
+		step = 1
+	}
+	for i := v.line + direction; i >= 0 && i < len(h.lines); i += step {
+		if isChange(h.lines[i]) {
+			v.line = i
+			return
+		}
+	}
+}
+
+// apply stages the selected lines of the current hunk when showing the
+// unstaged side, or unstages them when showing the staged one.
+func (v *patchView) apply() {
+	h, ok := v.current()
+	if !ok {
+		return
+	}
+	if err := applyToIndex(v.diff.patch(h, v.selected, v.staged), v.staged); err != nil {
+		v.err = strings.TrimSpace(err.Error())
+		return
+	}
+	v.err = ""
+	if err := v.load(); err != nil {
+		v.err = err.Error()
+	}
+}
+
+// update handles a key press, returning false when going back to the files.
+func (v *patchView) update(msg tea.KeyMsg) bool {
+	switch {
+	case key.Matches(msg, patchKeys.Back):
+		return false
+	case key.Matches(msg, patchKeys.Up):
+		v.moveLine(-1)
+	case key.Matches(msg, patchKeys.Down):
+		v.moveLine(1)
+	case key.Matches(msg, patchKeys.PrevHunk):
+		if v.hunk > 0 {
+			v.selectHunk(v.hunk - 1)
+		}
+	case key.Matches(msg, patchKeys.NextHunk):
+		if v.hunk < len(v.diff.hunks)-1 {
+			v.selectHunk(v.hunk + 1)
+		}
+	case key.Matches(msg, patchKeys.Select):
+		if h, ok := v.current(); ok && isChange(h.lines[v.line]) {
+			v.selected[v.line] = !v.selected[v.line]
+			if !v.selected[v.line] {
+				delete(v.selected, v.line)
+			}
+		}
+	case key.Matches(msg, patchKeys.Stage) && !v.staged,
+		key.Matches(msg, patchKeys.Unstage) && v.staged:
+		v.apply()
+	case key.Matches(msg, patchKeys.Switch):
+		v.staged = !v.staged
+		v.hunk = 0
+		v.err = ""
+		if err := v.load(); err != nil {
+			v.err = err.Error()
+		}
+	}
+	return true
+}
+
+func (v *patchView) view() string {
+	side, other := "Unstaged", "staged"
+	if v.staged {
+		side, other = "Staged", "unstaged"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s changes to %s", side, selectedItemStyle.Render(v.path))
+	h, ok := v.current()
+	if !ok {
+		fmt.Fprintf(&b, "\n\nNo %s changes left (tab to show the %s ones)\n", strings.ToLower(side), other)
+		return b.String() + v.help()
+	}
+	fmt.Fprintf(&b, " (hunk %d of %d)\n\n%s\n", v.hunk+1, len(v.diff.hunks), contextLineStyle.Render(h.header))
+
+	// Only as many lines as fit, around the cursor
+	start := 0
+	if v.line >= patchHeight {
+		start = v.line - patchHeight + 1
+	}
+	for i := start; i < len(h.lines) && i < start+patchHeight; i++ {
+		line := h.lines[i]
+		cursor, mark := "  ", " "
+		if i == v.line {
+			cursor = "> "
+		}
+		if v.selected[i] {
+			mark = "•"
+		}
+		switch {
+		case strings.HasPrefix(line, "+"):
+			line = addedLineStyle.Render(line)
+		case strings.HasPrefix(line, "-"):
+			line = removedLineStyle.Render(line)
+		default:
+			line = contextLineStyle.Render(line)
+		}
+		b.WriteString(cursor + mark + " " + line + "\n")
+	}
+	if v.err != "" {
+		b.WriteString("\n" + errorStyle.Render("✗ "+v.err) + "\n")
+	}
+	return b.String() + v.help()
+}
+
+func (v *patchView) help() string {
+	apply := patchKeys.Stage
+	if v.staged {
+		apply = patchKeys.Unstage
+	}
+	bindings := []key.Binding{patchKeys.Up, patchKeys.Down, patchKeys.PrevHunk, patchKeys.NextHunk, patchKeys.Select, apply, patchKeys.Switch, patchKeys.Back}
+	return "\n" + help.New().ShortHelpView(bindings) + "\n"
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDiffHeader = `diff --git a/f b/f
+index 94ebaf9..4f2a3b1 100644
+--- a/f
++++ b/f
+`
+
+func TestFileDiffPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		hunk     string
+		selected []int
+		reverse  bool
+		want     string
+	}{
+		{
+			name: "everything",
+			hunk: "@@ -1,4 +1,4 @@\n 1\n-2\n-3\n+two\n+three\n 4",
+			want: "@@ -1 +1 @@\n 1\n-2\n-3\n+two\n+three\n 4",
+		},
+		{
+			name:     "first line of a change",
+			hunk:     "@@ -1,4 +1,4 @@\n 1\n-2\n-3\n+two\n+three\n 4",
+			selected: []int{1, 3},
+			want:     "@@ -1 +1 @@\n 1\n-2\n+two\n 3\n 4",
+		},
+		{
+			name:     "last line of a change",
+			hunk:     "@@ -1,4 +1,4 @@\n 1\n-2\n-3\n+two\n+three\n 4",
+			selected: []int{2, 4},
+			want:     "@@ -1 +1 @@\n 1\n 2\n-3\n+three\n 4",
+		},
+		{
+			name:     "unstaging the first line of a change",
+			hunk:     "@@ -1,4 +1,4 @@\n 1\n-2\n-3\n+two\n+three\n 4",
+			selected: []int{1, 3},
+			reverse:  true,
+			want:     "@@ -1 +1 @@\n 1\n-2\n+two\n three\n 4",
+		},
+		{
+			name:     "unstaging the last addition",
+			hunk:     "@@ -1,4 +1,4 @@\n 1\n-2\n-3\n+two\n+three\n 4",
+			selected: []int{4},
+			reverse:  true,
+			want:     "@@ -1 +1 @@\n 1\n two\n+three\n 4",
+		},
+		{
+			name:     "addition before a line without a newline",
+			hunk:     "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file",
+			selected: []int{3},
+			want:     "@@ -1 +1 @@\n a\n+c\n b\n\\ No newline at end of file",
+		},
+		{
+			name:     "removal of a line without a newline",
+			hunk:     "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file",
+			selected: []int{1},
+			want:     "@@ -1 +1 @@\n a\n-b\n\\ No newline at end of file",
+		},
+		{
+			name:     "unstaging the removal of a line without a newline",
+			hunk:     "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file",
+			selected: []int{1},
+			reverse:  true,
+			want:     "@@ -1 +1 @@\n a\n-c\n+c\n\\ No newline at end of file\n-b\n\\ No newline at end of file",
+		},
+		{
+			name:     "unstaging the addition of a line without a newline",
+			hunk:     "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file",
+			selected: []int{3},
+			reverse:  true,
+			want:     "@@ -1 +1 @@\n a\n+c\n\\ No newline at end of file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := parseDiff(testDiffHeader + tt.hunk + "\n")
+			if len(d.hunks) != 1 {
+				t.Fatalf("parsed %d hunks, want 1", len(d.hunks))
+			}
+			selected := make(map[int]bool)
+			for _, i := range tt.selected {
+				selected[i] = true
+			}
+			want := testDiffHeader + tt.want + "\n"
+			if got := d.patch(d.hunks[0], selected, tt.reverse); got != want {
+				t.Errorf("patch() = %q, want %q", strings.TrimPrefix(got, testDiffHeader), tt.want+"\n")
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	formatText   = "text"
+	formatJSON   = "json"
+	formatJUnit  = "junit"
+	formatSARIF  = "sarif"
+	formatGitHub = "github"
+)
+
+// lintResult holds the diagnostics of a single linted message.
+type lintResult struct {
+	target      lintTarget
+	diagnostics []diagnostic
+}
+
+func (r lintResult) header() string {
+	header, _, _ := strings.Cut(strings.TrimSpace(r.target.message), "\n")
+	return header
+}
+
+func (r lintResult) count(severity string) int {
+	count := 0
+	for _, d := range r.diagnostics {
+		if d.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+var reportWriters = map[string]func(io.Writer, []lintResult) error{
+	formatText:   writeTextReport,
+	formatJSON:   writeJSONReport,
+	formatJUnit:  writeJUnitReport,
+	formatSARIF:  writeSARIFReport,
+	formatGitHub: writeGitHubReport,
+}
+
+func writeTextReport(w io.Writer, results []lintResult) error {
+	for _, r := range results {
+		for _, d := range r.diagnostics {
+			if _, err := fmt.Fprintf(w, "%s: %s\n", r.target.source, d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonDiagnostic is a diagnostic along with the commit it concerns, if any,
+// so that each can be told apart without the result it is in.
+type jsonDiagnostic struct {
+	Commit    string `json:"commit,omitempty"`
+	Rule      string `json:"rule"`
+	Severity  string `json:"severity"`
+	Message   string `json:"message"`
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	EndColumn int    `json:"endColumn,omitempty"`
+}
+
+type jsonResult struct {
+	Source       string           `json:"source"`
+	Commit       string           `json:"commit,omitempty"`
+	Header       string           `json:"header"`
+	ErrorCount   int              `json:"errorCount"`
+	WarningCount int              `json:"warningCount"`
+	Diagnostics  []jsonDiagnostic `json:"diagnostics"`
+}
+
+func writeJSONReport(w io.Writer, results []lintResult) error {
+	report := make([]jsonResult, len(results))
+	for i, r := range results {
+		report[i] = jsonResult{
+			Source:       r.target.source,
+			Commit:       r.target.commit,
+			Header:       r.header(),
+			ErrorCount:   r.count(severityError),
+			WarningCount: r.count(severityWarning),
+			Diagnostics:  make([]jsonDiagnostic, len(r.diagnostics)),
+		}
+		for j, d := range r.diagnostics {
+			report[i].Diagnostics[j] = jsonDiagnostic{
+				Commit:    r.target.commit,
+				Rule:      d.Rule,
+				Severity:  d.Severity,
+				Message:   d.Message,
+				Line:      d.Line,
+				Column:    d.Column,
+				EndColumn: d.EndColumn,
+			}
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name      string         `xml:"name,attr"`
+	ClassName string         `xml:"classname,attr"`
+	Failures  []junitFailure `xml:"failure"`
+	SystemOut string         `xml:"system-out,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// writeJUnitReport writes every message as a test case that fails when there
+// are errors, while warnings are only included in its output.
+func writeJUnitReport(w io.Writer, results []lintResult) error {
+	suite := junitTestSuite{Name: applicationName, Tests: len(results)}
+	for _, r := range results {
+		testCase := junitTestCase{Name: r.header(), ClassName: r.target.source}
+		var warnings []string
+		for _, d := range r.diagnostics {
+			location := r.target.source
+			if d.Line > 0 {
+				location = fmt.Sprintf("%s:%d:%d-%d", location, d.Line, d.Column, d.EndColumn)
+			}
+			if d.Severity != severityError {
+				warnings = append(warnings, fmt.Sprintf("%s: %s", location, d))
+				continue
+			}
+			testCase.Failures = append(testCase.Failures, junitFailure{
+				Message: d.Message,
+				Type:    d.Rule,
+				Text:    fmt.Sprintf("%s: %s", location, d),
+			})
+		}
+		if len(testCase.Failures) > 0 {
+			suite.Failures++
+		}
+		testCase.SystemOut = strings.Join(warnings, "\n")
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndColumn   int `json:"endColumn"`
+}
+
+type sarifArtifactLocation struct {
+	URI         string        `json:"uri,omitempty"`
+	Description *sarifMessage `json:"description,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifLogicalLocation struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+// writeSARIFReport writes a SARIF 2.1.0 log. Commit messages are not files, so
+// problems in them are located by a description of the artifact along with
+// the commit as a logical location.
+func writeSARIFReport(w io.Writer, results []lintResult) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           applicationName,
+			Version:        pkgVersion(),
+			InformationURI: "https://github.com/usrme/cometary",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	seenRules := make(map[string]bool)
+	for _, r := range results {
+		for _, d := range r.diagnostics {
+			if !seenRules[d.Rule] {
+				seenRules[d.Rule] = true
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: d.Rule})
+			}
+
+			location := sarifLocation{}
+			if r.target.commit != "" {
+				location.PhysicalLocation.ArtifactLocation.Description = &sarifMessage{Text: "message of commit " + r.target.commit}
+				location.LogicalLocations = []sarifLogicalLocation{{Name: r.target.commit, Kind: "commit"}}
+			} else {
+				location.PhysicalLocation.ArtifactLocation.URI = r.target.source
+			}
+			if d.Line > 0 {
+				// The end column is exclusive in SARIF
+				location.PhysicalLocation.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column, EndColumn: d.EndColumn + 1}
+			}
+
+			level := "error"
+			if d.Severity == severityWarning {
+				level = "warning"
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    d.Rule,
+				Level:     level,
+				Message:   sarifMessage{Text: d.Message},
+				Locations: []sarifLocation{location},
+			})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// escapeWorkflowCommand escapes the data, or a property when it is one, of a
+// GitHub Actions workflow command.
+func escapeWorkflowCommand(s string, property bool) string {
+	s = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+	if property {
+		s = strings.NewReplacer(":", "%3A", ",", "%2C").Replace(s)
+	}
+	return s
+}
+
+// writeGitHubReport writes workflow commands that make GitHub Actions show
+// every problem as an annotation.
+func writeGitHubReport(w io.Writer, results []lintResult) error {
+	for _, r := range results {
+		for _, d := range r.diagnostics {
+			command := "error"
+			if d.Severity == severityWarning {
+				command = "warning"
+			}
+
+			var properties []string
+			message := d.Message
+			if r.target.commit != "" {
+				message = fmt.Sprintf("%s: %s", r.target.commit, d.Message)
+			} else {
+				properties = append(properties, "file="+escapeWorkflowCommand(r.target.source, true))
+				if d.Line > 0 {
+					properties = append(properties,
+						fmt.Sprintf("line=%d", d.Line),
+						fmt.Sprintf("col=%d", d.Column),
+						fmt.Sprintf("endColumn=%d", d.EndColumn),
+					)
+				}
+			}
+			properties = append(properties, "title="+escapeWorkflowCommand(fmt.Sprintf("%s [%s]", applicationName, d.Rule), true))
+
+			if _, err := fmt.Fprintf(w, "::%s %s::%s\n", command, strings.Join(properties, ","), escapeWorkflowCommand(message, false)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
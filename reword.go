@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var defaultProtectedBranches = []string{"main", "master"}
+
+func runReword(c *config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s reword <rev>", applicationName)
+	}
+
+	if err := findGitDir(); err != nil {
+		return err
+	}
+
+	sha, err := revParse(args[0])
+	if err != nil {
+		return err
+	}
+
+	parents, err := parentsOfCommit(sha)
+	if err != nil {
+		return err
+	}
+	if len(parents) > 1 {
+		return fmt.Errorf("refusing to reword merge commit %s", args[0])
+	}
+
+	protected := c.ProtectedBranches
+	if protected == nil {
+		protected = defaultProtectedBranches
+	}
+	branch, err := protectedBranchContaining(sha, protected)
+	if err != nil {
+		return err
+	}
+	if branch != "" {
+		return fmt.Errorf("refusing to reword %s as it is already on %s", args[0], branch)
+	}
+
+	text, err := messageOfCommit(sha)
+	if err != nil {
+		return err
+	}
+	files, err := filesInCommit(sha)
+	if err != nil {
+		return err
+	}
+
+	m := newModel(c, files, "")
+	m.Prefill(parseCommitMessage(text))
+	if _, err := tea.NewProgram(m).Run(); err != nil {
+		return err
+	}
+
+	fmt.Println("")
+	if !m.Finished() {
+		return fmt.Errorf("terminated")
+	}
+
+	f, err := os.CreateTemp("", applicationName+"-reword-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	msg, withBody := m.CommitMessage()
+	if _, err := f.WriteString(msg + "\n"); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if withBody {
+		if err := editFile(f.Name()); err != nil {
+			return fmt.Errorf("error editing message: %s", err)
+		}
+	}
+
+	if err := rewordCommit(sha, len(parents) == 0, f.Name(), c.SignOffCommits); err != nil {
+		return fmt.Errorf("error rewording: %s", err)
+	}
+	return nil
+}
+
+// runSequenceEditor is invoked by git during the rebase started by
+// rewordCommit and adds a step that replaces the message of the commit right
+// after it has been picked.
+func runSequenceEditor(_ *config, args []string) error {
+	if len(args) != 4 {
+		return fmt.Errorf("usage: %s sequence-editor <sha> <message file> <sign-off> <todo file>", applicationName)
+	}
+	sha, msgFile, signOff, todoFile := args[0], args[1], args[2], args[3]
+
+	data, err := os.ReadFile(todoFile)
+	if err != nil {
+		return err
+	}
+
+	step := "exec git commit --amend --only --cleanup=strip -F " + shellQuote(msgFile)
+	if signOff == "true" {
+		step += " -s"
+	}
+
+	var lines []string
+	found := false
+	for _, line := range strings.Split(string(data), "\n") {
+		lines = append(lines, line)
+		fields := strings.Fields(line)
+		if len(fields) > 1 && (fields[0] == "pick" || fields[0] == "p") && strings.HasPrefix(sha, fields[1]) {
+			lines = append(lines, step)
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("commit %s not found in rebase todo list", sha)
+	}
+
+	return os.WriteFile(todoFile, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	ruleError   = "error"
+	ruleWarning = "warn"
+)
+
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}_-]+`)
+
+type rule struct {
+	Severity string `json:"severity"`
+}
+
+type stringRule struct {
+	Severity string `json:"severity"`
+	Value    string `json:"value"`
+}
+
+type intRule struct {
+	Severity string `json:"severity"`
+	Value    int    `json:"value"`
+}
+
+type listRule struct {
+	Severity string   `json:"severity"`
+	Value    []string `json:"value"`
+}
+
+type imperativeRule struct {
+	Severity  string            `json:"severity"`
+	Overrides map[string]string `json:"overrides"`
+}
+
+// rules holds the optional checks on top of the prefixes, scopes and
+// character limits. Every rule is off unless given a severity of "error" or
+// "warn", and errors prevent moving on to the next prompt.
+type rules struct {
+	SubjectCase           stringRule     `json:"subjectCase"`
+	NoTrailingPeriod      rule           `json:"noTrailingPeriod"`
+	SubjectLineMaxLength  intRule        `json:"subjectLineMaxLength"`
+	BodyLineMaxLength     intRule        `json:"bodyLineMaxLength"`
+	BlankLineAfterSubject rule           `json:"blankLineAfterSubject"`
+	ForbiddenWords        listRule       `json:"forbiddenWords"`
+	RequiredFooters       listRule       `json:"requiredFooters"`
+	ScopePattern          stringRule     `json:"scopePattern"`
+	HeaderPattern         stringRule     `json:"headerPattern"`
+	ImperativeMood        imperativeRule `json:"imperativeMood"`
+	Spelling              spellingRule   `json:"spelling"`
+}
+
+func newDiagnostic(ruleSeverity string, id string, format string, args ...interface{}) diagnostic {
+	severity := severityError
+	if ruleSeverity == ruleWarning {
+		severity = severityWarning
+	}
+	return diagnostic{Rule: id, Severity: severity, Message: fmt.Sprintf(format, args...)}
+}
+
+func enabled(severity string) bool {
+	return severity == ruleError || severity == ruleWarning
+}
+
+// checkScope evaluates the rules that concern the scope.
+func (r rules) checkScope(scope string) []diagnostic {
+	var diagnostics []diagnostic
+	if enabled(r.ScopePattern.Severity) && scope != "" {
+		pattern, err := regexp.Compile("^(?:" + r.ScopePattern.Value + ")$")
+		if err != nil {
+			diagnostics = append(diagnostics, newDiagnostic(ruleError, "scope-pattern", "invalid pattern %q: %s", r.ScopePattern.Value, err))
+		} else if !pattern.MatchString(scope) {
+			d := newDiagnostic(r.ScopePattern.Severity, "scope-pattern", "scope %q must match %q", scope, r.ScopePattern.Value)
+			diagnostics = append(diagnostics, d.span(scope, 0, len(scope)))
+		}
+	}
+	return diagnostics
+}
+
+// checkSubject evaluates the rules that concern the first line, which ends
+// with the subject.
+func (r rules) checkSubject(header string, subject string) []diagnostic {
+	var diagnostics []diagnostic
+	if strings.TrimSpace(subject) == "" {
+		return diagnostics
+	}
+	start := len(header) - len(subject)
+
+	if enabled(r.SubjectCase.Severity) {
+		first, size := utf8.DecodeRuneInString(subject)
+		var d diagnostic
+		switch {
+		case r.SubjectCase.Value == "lower" && unicode.IsUpper(first):
+			d = newDiagnostic(r.SubjectCase.Severity, "subject-case", "message must start with a lower-case letter")
+		case r.SubjectCase.Value == "upper" && unicode.IsLower(first):
+			d = newDiagnostic(r.SubjectCase.Severity, "subject-case", "message must start with an upper-case letter")
+		}
+		if d.Rule != "" {
+			diagnostics = append(diagnostics, d.span(header, start, start+size))
+		}
+	}
+
+	if enabled(r.ImperativeMood.Severity) {
+		word := strings.Fields(subject)[0]
+		if verb, ok := imperativeForm(word, r.ImperativeMood.Overrides); ok {
+			d := newDiagnostic(r.ImperativeMood.Severity, "imperative-mood", "use %q instead of %q", verb, word)
+			wordStart := start + strings.Index(subject, word)
+			diagnostics = append(diagnostics, d.span(header, wordStart, wordStart+len(word)))
+		}
+	}
+
+	if enabled(r.NoTrailingPeriod.Severity) && strings.HasSuffix(subject, ".") {
+		d := newDiagnostic(r.NoTrailingPeriod.Severity, "no-trailing-period", "message must not end with a period")
+		diagnostics = append(diagnostics, d.span(header, len(header)-1, len(header)))
+	}
+
+	if enabled(r.SubjectLineMaxLength.Severity) && r.SubjectLineMaxLength.Value > 0 && len(header) > r.SubjectLineMaxLength.Value {
+		d := newDiagnostic(
+			r.SubjectLineMaxLength.Severity,
+			"subject-line-max-length",
+			"first line is %d characters long, limit is %d", len(header), r.SubjectLineMaxLength.Value,
+		)
+		diagnostics = append(diagnostics, d.span(header, r.SubjectLineMaxLength.Value, len(header)))
+	}
+
+	if enabled(r.HeaderPattern.Severity) {
+		pattern, err := regexp.Compile(r.HeaderPattern.Value)
+		if err != nil {
+			diagnostics = append(diagnostics, newDiagnostic(ruleError, "header-pattern", "invalid pattern %q: %s", r.HeaderPattern.Value, err))
+		} else if !pattern.MatchString(header) {
+			d := newDiagnostic(r.HeaderPattern.Severity, "header-pattern", "first line must match %q", r.HeaderPattern.Value)
+			diagnostics = append(diagnostics, d.span(header, 0, len(header)))
+		}
+	}
+
+	diagnostics = append(diagnostics, r.checkForbiddenWords(header, start, len(header))...)
+	diagnostics = append(diagnostics, r.checkSpelling(header, start, len(header))...)
+	return diagnostics
+}
+
+// checkBody evaluates the rules that concern everything after the first
+// line, using the raw text as the parsed message no longer has the blank
+// lines.
+func (r rules) checkBody(text string, msg commitMessage) []diagnostic {
+	var diagnostics []diagnostic
+	text = strings.TrimSpace(text)
+	lines := strings.Split(text, "\n")
+
+	if enabled(r.BlankLineAfterSubject.Severity) && len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		d := newDiagnostic(r.BlankLineAfterSubject.Severity, "blank-line-after-subject", "first line must be followed by a blank line")
+		lineStart := len(lines[0]) + 1
+		diagnostics = append(diagnostics, d.span(text, lineStart, lineStart+len(lines[1])))
+	}
+
+	if enabled(r.BodyLineMaxLength.Severity) && r.BodyLineMaxLength.Value > 0 {
+		lineStart := len(lines[0]) + 1
+		for i, line := range lines[1:] {
+			if len(line) > r.BodyLineMaxLength.Value {
+				d := newDiagnostic(
+					r.BodyLineMaxLength.Severity,
+					"body-line-max-length",
+					"line %d is %d characters long, limit is %d", i+2, len(line), r.BodyLineMaxLength.Value,
+				)
+				diagnostics = append(diagnostics, d.span(text, lineStart+r.BodyLineMaxLength.Value, lineStart+len(line)))
+			}
+			lineStart += len(line) + 1
+		}
+	}
+
+	if msg.Body != "" {
+		// The body is checked where it is found in the text, so that the
+		// problems point to the right lines
+		base, start := text, strings.Index(text, msg.Body)
+		if start < 0 {
+			base, start = msg.Body, 0
+		}
+		diagnostics = append(diagnostics, r.checkForbiddenWords(base, start, start+len(msg.Body))...)
+		diagnostics = append(diagnostics, r.checkSpelling(base, start, start+len(msg.Body))...)
+	}
+
+	if enabled(r.RequiredFooters.Severity) {
+		for _, token := range r.RequiredFooters.Value {
+			found := false
+			for _, f := range msg.Footers {
+				if strings.EqualFold(f.Token, token) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				diagnostics = append(diagnostics, newDiagnostic(r.RequiredFooters.Severity, "required-footers", "footer %q is missing", token))
+			}
+		}
+	}
+	return diagnostics
+}
+
+// checkForbiddenWords looks for the forbidden words in the part of the text
+// between the start and end offsets.
+func (r rules) checkForbiddenWords(text string, start int, end int) []diagnostic {
+	var diagnostics []diagnostic
+	if !enabled(r.ForbiddenWords.Severity) {
+		return diagnostics
+	}
+
+	words := wordPattern.FindAllStringIndex(text[start:end], -1)
+	for _, forbidden := range r.ForbiddenWords.Value {
+		for _, loc := range words {
+			if strings.EqualFold(text[start+loc[0]:start+loc[1]], forbidden) {
+				d := newDiagnostic(r.ForbiddenWords.Severity, "forbidden-words", "word %q must not be used", forbidden)
+				diagnostics = append(diagnostics, d.span(text, start+loc[0], start+loc[1]))
+				break
+			}
+		}
+	}
+	return diagnostics
+}
+
+// checkSpelling looks for misspelled words in the part of the text between
+// the start and end offsets.
+func (r rules) checkSpelling(text string, start int, end int) []diagnostic {
+	var diagnostics []diagnostic
+	if !enabled(r.Spelling.Severity) {
+		return diagnostics
+	}
+
+	for _, m := range r.Spelling.misspellings(text[start:end]) {
+		message := fmt.Sprintf("unknown word %q", m.word)
+		if len(m.suggestions) > 0 {
+			message += ", did you mean: " + strings.Join(m.suggestions, ", ")
+		}
+		d := newDiagnostic(r.Spelling.Severity, "spelling", "%s", message)
+		diagnostics = append(diagnostics, d.span(text, start+m.start, start+m.end))
+	}
+	return diagnostics
+}
+
+// check evaluates every rule against a complete message.
+func (r rules) check(text string, msg commitMessage) []diagnostic {
+	var diagnostics []diagnostic
+	diagnostics = append(diagnostics, shiftColumns(r.checkScope(msg.Scope), utf8.RuneCountInString(msg.Prefix)+1)...)
+	diagnostics = append(diagnostics, r.checkSubject(msg.Header(), msg.Subject)...)
+	diagnostics = append(diagnostics, r.checkBody(text, msg)...)
+	return diagnostics
+}
+
+func hasErrors(diagnostics []diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == severityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"embed"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// The dictionary is the Hunspell one for American English derived from SCOWL,
+// see dictionary/LICENSE.
+//
+//go:embed dictionary/en_US.aff dictionary/en_US.dic
+var dictionaryFiles embed.FS
+
+const (
+	defaultWordList     = ".cometary-words"
+	maxSpellSuggestions = 3
+)
+
+var (
+	tokenPattern      = regexp.MustCompile(`\S+`)
+	codeSpanPattern   = regexp.MustCompile("`[^`]*`")
+	identifierPattern = regexp.MustCompile(`[A-Za-z][A-Za-z0-9_]*`)
+	dictionary        map[string]bool
+	loadDictionary    sync.Once
+	wordLists         = make(map[string]map[string]bool)
+	wordListsMutex    sync.Mutex
+)
+
+type spellingRule struct {
+	Severity string `json:"severity"`
+	WordList string `json:"wordList"`
+	// Words known to be fine in addition to the dictionary and word list,
+	// such as identifiers from the staged changes
+	known map[string]bool
+}
+
+type misspelling struct {
+	word        string
+	start       int
+	end         int
+	suggestions []string
+}
+
+type affix struct {
+	strip     string
+	add       string
+	condition *regexp.Regexp
+}
+
+type affixClass struct {
+	prefix  bool
+	cross   bool
+	entries []affix
+}
+
+// parseAffixes reads the prefix and suffix classes of a Hunspell affix file,
+// which is all that is needed to expand the dictionary into every word form.
+func parseAffixes(data string) map[byte]*affixClass {
+	classes := make(map[byte]*affixClass)
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || (fields[0] != "PFX" && fields[0] != "SFX") || len(fields[1]) != 1 {
+			continue
+		}
+		flag := fields[1][0]
+
+		class, ok := classes[flag]
+		if !ok {
+			classes[flag] = &affixClass{prefix: fields[0] == "PFX", cross: fields[2] == "Y"}
+			continue
+		}
+		if len(fields) < 5 {
+			continue
+		}
+
+		a := affix{strip: fields[2], add: fields[3]}
+		if a.strip == "0" {
+			a.strip = ""
+		}
+		if a.add == "0" {
+			a.add = ""
+		}
+		if cond := fields[4]; cond != "." {
+			if class.prefix {
+				a.condition = regexp.MustCompile("^(?:" + cond + ")")
+			} else {
+				a.condition = regexp.MustCompile("(?:" + cond + ")$")
+			}
+		}
+		class.entries = append(class.entries, a)
+	}
+	return classes
+}
+
+func (a affix) apply(word string, prefix bool) (string, bool) {
+	if a.condition != nil && !a.condition.MatchString(word) {
+		return "", false
+	}
+	if prefix {
+		if !strings.HasPrefix(word, a.strip) {
+			return "", false
+		}
+		return a.add + word[len(a.strip):], true
+	}
+	if !strings.HasSuffix(word, a.strip) {
+		return "", false
+	}
+	return word[:len(word)-len(a.strip)] + a.add, true
+}
+
+func expandWord(word string, flags string, classes map[byte]*affixClass, words map[string]bool) {
+	words[strings.ToLower(word)] = true
+
+	var prefixes, suffixes []*affixClass
+	for i := 0; i < len(flags); i++ {
+		if class, ok := classes[flags[i]]; ok {
+			if class.prefix {
+				prefixes = append(prefixes, class)
+			} else {
+				suffixes = append(suffixes, class)
+			}
+		}
+	}
+
+	for _, p := range prefixes {
+		for _, a := range p.entries {
+			if form, ok := a.apply(word, true); ok {
+				words[strings.ToLower(form)] = true
+			}
+		}
+	}
+	for _, s := range suffixes {
+		for _, a := range s.entries {
+			form, ok := a.apply(word, false)
+			if !ok {
+				continue
+			}
+			words[strings.ToLower(form)] = true
+			if !s.cross {
+				continue
+			}
+			for _, p := range prefixes {
+				if !p.cross {
+					continue
+				}
+				for _, pa := range p.entries {
+					if crossed, ok := pa.apply(form, true); ok {
+						words[strings.ToLower(crossed)] = true
+					}
+				}
+			}
+		}
+	}
+}
+
+func readDictionary() map[string]bool {
+	words := make(map[string]bool)
+	aff, err := dictionaryFiles.ReadFile("dictionary/en_US.aff")
+	if err != nil {
+		return words
+	}
+	dic, err := dictionaryFiles.ReadFile("dictionary/en_US.dic")
+	if err != nil {
+		return words
+	}
+
+	classes := parseAffixes(string(aff))
+	lines := strings.Split(string(dic), "\n")
+	// The first line only holds the number of entries
+	for _, line := range lines[1:] {
+		word, flags, _ := strings.Cut(strings.TrimSpace(line), "/")
+		if word == "" {
+			continue
+		}
+		expandWord(word, flags, classes, words)
+	}
+	return words
+}
+
+// cachedWordList returns the words of the word list, reading the file only
+// the first time around as the prompts check the spelling on every key press.
+func cachedWordList(path string) map[string]bool {
+	wordListsMutex.Lock()
+	defer wordListsMutex.Unlock()
+	if words, ok := wordLists[path]; ok {
+		return words
+	}
+	wordLists[path] = readWordList(path)
+	return wordLists[path]
+}
+
+// readWordList reads the words, one per line, that are accepted in addition
+// to the dictionary. Relative paths are relative to the repository root.
+func readWordList(path string) map[string]bool {
+	words := make(map[string]bool)
+	if path == "" {
+		path = defaultWordList
+	}
+	if !filepath.IsAbs(path) {
+		root, err := repoRoot()
+		if err != nil {
+			return words
+		}
+		path = filepath.Join(root, path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return words
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words[strings.ToLower(line)] = true
+	}
+	return words
+}
+
+// identifierWords splits identifiers and paths into the lower-case words they
+// consist of, so that these are not flagged when mentioned in a message.
+func identifierWords(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, id := range identifierPattern.FindAllString(text, -1) {
+		words[strings.ToLower(id)] = true
+		for _, part := range strings.Split(id, "_") {
+			if part != "" {
+				words[strings.ToLower(part)] = true
+			}
+		}
+	}
+	return words
+}
+
+// looksLikeCode reports whether the token is something other than a plain
+// word, e.g. an identifier, a path, a URL or a version number.
+func looksLikeCode(token string) bool {
+	if strings.ContainsAny(token, "_/\\.@#=<>{}[]()0123456789") {
+		return true
+	}
+	upper := 0
+	runes := []rune(token)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			upper++
+			// camelCase or PascalCase
+			if i > 0 && unicode.IsLower(runes[i-1]) {
+				return true
+			}
+		}
+	}
+	// Acronyms
+	return upper > 1 && upper == len(runes)
+}
+
+// misspellings returns the words in the text that are not found in the
+// dictionary, the word list or among the known words.
+func (r spellingRule) misspellings(text string) []misspelling {
+	loadDictionary.Do(func() {
+		dictionary = readDictionary()
+	})
+	wordList := cachedWordList(r.WordList)
+
+	// Blank out code spans while keeping the offsets intact
+	text = codeSpanPattern.ReplaceAllStringFunc(text, func(s string) string {
+		return strings.Repeat(" ", len(s))
+	})
+	text = strings.ReplaceAll(text, "’", "'")
+
+	var found []misspelling
+	for _, loc := range tokenPattern.FindAllStringIndex(text, -1) {
+		token := text[loc[0]:loc[1]]
+		trimmed := strings.TrimLeft(token, `"'*,:;!?`)
+		start := loc[0] + len(token) - len(trimmed)
+		trimmed = strings.TrimRight(trimmed, `"'*,.:;!?`)
+		if trimmed == "" || strings.Contains(trimmed, "://") || looksLikeCode(trimmed) {
+			continue
+		}
+
+		offset := start
+		for _, word := range strings.Split(trimmed, "-") {
+			wordStart := offset
+			offset += len(word) + 1
+			lower := strings.ToLower(strings.TrimSuffix(word, "'s"))
+			if lower == "" || dictionary[lower] || wordList[lower] || r.known[lower] {
+				continue
+			}
+			found = append(found, misspelling{
+				word:        word,
+				start:       wordStart,
+				end:         wordStart + len(word),
+				suggestions: spellSuggestions(lower),
+			})
+		}
+	}
+	return found
+}
+
+// spellSuggestions returns dictionary words that are a single edit away.
+func spellSuggestions(word string) []string {
+	const letters = "abcdefghijklmnopqrstuvwxyz'"
+	seen := make(map[string]bool)
+	var suggestions []string
+	add := func(candidate string) {
+		if candidate != word && dictionary[candidate] && !seen[candidate] {
+			seen[candidate] = true
+			suggestions = append(suggestions, candidate)
+		}
+	}
+
+	// Swapped letters are the most likely typo, followed by a wrong letter
+	for i := 0; i < len(word)-1; i++ {
+		add(word[:i] + string(word[i+1]) + string(word[i]) + word[i+2:])
+	}
+	for i := 0; i < len(word); i++ {
+		for _, l := range letters {
+			add(word[:i] + string(l) + word[i+1:])
+		}
+	}
+	for i := 0; i <= len(word); i++ {
+		if i < len(word) {
+			add(word[:i] + word[i+1:])
+		}
+		for _, l := range letters {
+			add(word[:i] + string(l) + word[i:])
+		}
+	}
+
+	// Prefer suggestions that keep the first letter, as that is rarely wrong
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i][0] == word[0] && suggestions[j][0] != word[0]
+	})
+	if len(suggestions) > maxSpellSuggestions {
+		suggestions = suggestions[:maxSpellSuggestions]
+	}
+	return suggestions
+}
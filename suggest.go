@@ -0,0 +1,106 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+type prefixRule struct {
+	Prefix string   `json:"prefix"`
+	Paths  []string `json:"paths"`
+}
+
+var defaultPrefixRules = []prefixRule{
+	{
+		Prefix: "docs",
+		Paths:  []string{"*.md"},
+	},
+	{
+		Prefix: "test",
+		Paths:  []string{"*_test.go"},
+	},
+	{
+		Prefix: "ci",
+		Paths:  []string{".github/workflows/**"},
+	},
+	{
+		Prefix: "build",
+		Paths:  []string{"go.mod", "go.sum"},
+	},
+}
+
+// suggestPrefix returns the prefix of the first rule whose path patterns match
+// every one of the staged files, provided that prefix is also configured.
+func suggestPrefix(stagedFiles []string, rules []prefixRule, prefixes []prefix) string {
+	if len(stagedFiles) == 0 {
+		return ""
+	}
+
+	known := make(map[string]bool)
+	for _, p := range prefixes {
+		known[p.Title()] = true
+	}
+
+	for _, r := range rules {
+		if !known[r.Prefix] {
+			continue
+		}
+		if matchesAll(stagedFiles, r.Paths) {
+			return r.Prefix
+		}
+	}
+	return ""
+}
+
+func matchesAll(files []string, patterns []string) bool {
+	for _, f := range files {
+		matched := false
+		for _, p := range patterns {
+			if matchPath(p, f) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPath reports whether file matches pattern. Patterns without a slash are
+// matched against the base name only, much like in .gitignore, while patterns
+// with a slash are matched against the whole path with '**' standing in for
+// any number of directories.
+func matchPath(pattern, file string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(file))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
+
+func matchSegments(pattern, file []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Trailing '**' matches everything below the current directory
+			if len(pattern) == 1 {
+				return len(file) > 0
+			}
+			for i := 0; i <= len(file); i++ {
+				if matchSegments(pattern[1:], file[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(file) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], file[0]); !ok {
+			return false
+		}
+		pattern, file = pattern[1:], file[1:]
+	}
+	return len(file) == 0
+}
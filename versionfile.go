@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// versionPatterns are the patterns of the files that are recognized by name
+// when no pattern is given, each with the version as the first submatch.
+var versionPatterns = map[string]string{
+	"package.json": `^\s*"version"\s*:\s*"([^"]*)"`,
+	"Chart.yaml":   `^version:\s*["']?([^"'\s]*)`,
+}
+
+// updateVersion replaces the version in the text. With a pattern that has a
+// submatch, that is replaced. Otherwise every occurrence of the current
+// version is, limited to the lines that match the pattern if there is one.
+func updateVersion(text string, pattern string, current string, next string) (string, error) {
+	if pattern == "" {
+		if current == "" || !strings.Contains(text, current) {
+			return text, fmt.Errorf("version %q not found", current)
+		}
+		return strings.ReplaceAll(text, current, next), nil
+	}
+
+	re, err := regexp.Compile("(?m)" + pattern)
+	if err != nil {
+		return text, err
+	}
+	updated := false
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		loc := re.FindStringSubmatchIndex(line)
+		switch {
+		case loc == nil:
+			continue
+		case len(loc) >= 4 && loc[2] >= 0:
+			lines[i] = line[:loc[2]] + next + line[loc[3]:]
+		case current != "" && strings.Contains(line, current):
+			lines[i] = strings.ReplaceAll(line, current, next)
+		default:
+			continue
+		}
+		updated = true
+	}
+	if !updated {
+		return text, fmt.Errorf("no version found on lines matching %q", pattern)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// updateVersionFile returns the text of the file with the next version in
+// it, using the pattern of the file or one recognized by its name.
+func updateVersionFile(f versionFile, text string, current string, next string) (string, error) {
+	var err error
+	base := filepath.Base(f.Path)
+	builtin, known := versionPatterns[base]
+	var result string
+	switch {
+	// A file holding nothing but the version
+	case f.Pattern == "" && base == "VERSION":
+		result = next + "\n"
+	// Only the first match, as further ones may be those of dependencies
+	case f.Pattern == "" && known:
+		loc := regexp.MustCompile("(?m)" + builtin).FindStringIndex(text)
+		if loc == nil {
+			return "", fmt.Errorf("%s: no version found", f.Path)
+		}
+		line, err := updateVersion(text[loc[0]:loc[1]], builtin, current, next)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", f.Path, err)
+		}
+		result = text[:loc[0]] + line + text[loc[1]:]
+	default:
+		result, err = updateVersion(text, f.Pattern, current, next)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", f.Path, err)
+		}
+	}
+	return result, nil
+}
+
+// commitRelease updates the version files and the changelogs of the releases
+// and commits them together the same way any other commit is made, so the
+// hooks and sign-off settings apply.
+func commitRelease(c *config, plans []plannedRelease) error {
+	configured := c.ChangelogFile != ""
+	for _, plan := range plans {
+		configured = configured || len(plan.versionFiles) > 0
+	}
+	if !configured {
+		return fmt.Errorf("neither versionFiles nor changelogFile configured to update")
+	}
+
+	root, err := repoRoot()
+	if err != nil {
+		return err
+	}
+
+	// Nothing is written until every file could be updated, changelogs
+	// included, and a file can be listed more than once for different
+	// patterns
+	var paths []string
+	texts := make(map[string]string)
+	for _, plan := range plans {
+		for _, f := range plan.versionFiles {
+			f.Path = filepath.Join(plan.dir, f.Path)
+			path := filepath.Join(root, f.Path)
+			text, ok := texts[path]
+			if !ok {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				text = string(data)
+				paths = append(paths, path)
+			}
+			if texts[path], err = updateVersionFile(f, text, plan.current, plan.next); err != nil {
+				return err
+			}
+		}
+	}
+	var versions []string
+	renderer := newChangelogRenderer(c)
+	for _, plan := range plans {
+		versions = append(versions, plan.release.Version)
+		if c.ChangelogFile == "" {
+			continue
+		}
+		path := filepath.Join(root, plan.dir, c.ChangelogFile)
+		text, ok := texts[path]
+		if !ok {
+			data, err := os.ReadFile(path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			text = string(data)
+			paths = append(paths, path)
+		}
+		if texts[path], err = prependSection(path, text, plan.release.Version, renderer.markdown(plan.release)); err != nil {
+			return err
+		}
+	}
+	for _, path := range paths {
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode()
+		}
+		if err := os.WriteFile(path, []byte(texts[path]), mode); err != nil {
+			return err
+		}
+	}
+	if err := stageFiles(paths...); err != nil {
+		return err
+	}
+
+	// Only the files of the release are committed, leaving anything else
+	// that is staged as it is
+	msg := commitMessage{Prefix: "chore", Scope: "release", Subject: strings.Join(versions, ", ")}
+	return commitWithArgs(msg.String(), false, c.SignOffCommits, append([]string{"--"}, paths...))
+}